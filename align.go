@@ -0,0 +1,376 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+//-----------------------------------------------------------------------------
+// AlignOptions controls Align and AlignReads.  Zero values select defaults,
+// which follow BWA-MEM.
+//-----------------------------------------------------------------------------
+type AlignOptions struct {
+	MinSeedLen    int // shortest SMEM used as a seed; default 19
+	SeedLen       int // if > 0, seed with non-overlapping substrings of this length instead of SMEMs
+	MaxOcc        int // seeds occurring more often are skipped; default 500
+	MaxCandidates int // candidate loci extended per read; default 5
+	Band          int // diagonals the extension may stray from its seeds; default 20
+	Match         int // score of a match; default 1
+	Mismatch      int // penalty of a mismatch; default 4
+	GapOpen       int // penalty of opening a gap; default 6
+	GapExtend     int // penalty of each base of a gap; default 1
+	MinScore      int // reads scoring less are unmapped; default 30
+}
+
+//-----------------------------------------------------------------------------
+// Fills in defaults and checks that the options are consistent.
+func (opts *AlignOptions) validate() error {
+	defaults := []struct {
+		v   *int
+		def int
+	}{
+		{&opts.MinSeedLen, 19}, {&opts.MaxOcc, 500}, {&opts.MaxCandidates, 5}, {&opts.Band, 20},
+		{&opts.Match, 1}, {&opts.Mismatch, 4}, {&opts.GapOpen, 6}, {&opts.GapExtend, 1}, {&opts.MinScore, 30},
+	}
+	for _, d := range defaults {
+		if *d.v < 0 {
+			return fmt.Errorf("AlignOptions: options must not be negative, got %+v", *opts)
+		}
+		if *d.v == 0 {
+			*d.v = d.def
+		}
+	}
+	if opts.SeedLen < 0 {
+		return fmt.Errorf("AlignOptions: SeedLen must not be negative, got %d", opts.SeedLen)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Alignment is where a read aligns: Pos is the 0-based position, on the
+// forward strand of sequence SeqID, of the first aligned base.  If Reverse
+// is set, the reverse complement of the read aligns there, and Cigar
+// describes it.  SeqID is -1 if the read is unmapped.
+//-----------------------------------------------------------------------------
+type Alignment struct {
+	SeqID   int
+	Pos     int
+	Reverse bool
+	Cigar   string // SAM CIGAR, with M for matches and mismatches and S for clipped bases
+	Score   int    // Smith-Waterman score
+	Sub     int    // best score at another locus; 0 if none
+	MapQ    int    // mapping quality, 0 to 60
+	NM      int    // edit distance to the reference
+}
+
+//-----------------------------------------------------------------------------
+// Aligns read to the indexed sequences, on both strands: seeds from the
+// index are clustered by locus, the best candidate loci are extended with
+// banded Smith-Waterman against the text, and the mapping quality follows
+// from the gap between the best score and the best at another locus.
+// Locating seeds needs the suffix array or a sample of it.  The text
+// around each candidate is read from SEQ, or else recovered with at most
+// SA_RATE LF steps per base from the inverse sample (see
+// SampleSuffixArray); with neither, it is recovered from the end of the
+// text, which costs O(n) steps per candidate.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Align(read []byte, opts AlignOptions) (Alignment, error) {
+	if err := opts.validate(); err != nil {
+		return Alignment{}, err
+	}
+	return I.align(read, &opts), nil
+}
+
+//-----------------------------------------------------------------------------
+// A locus supported by seeds: diagonals, i.e. text offset minus read
+// offset, from lo to hi on one strand of a sequence, and the read bases
+// that the seeds cover.
+type candidate struct {
+	seq     int
+	reverse bool
+	lo, hi  int
+	covered int
+}
+
+func (I *IndexC) align(read []byte, opts *AlignOptions) Alignment {
+	unmapped := Alignment{SeqID: -1}
+	read, err := I.normalize_query(read)
+	if err != nil || len(read) == 0 {
+		return unmapped
+	}
+	strands := [2][]byte{read, ReverseComplement(read)}
+	var cands []candidate
+	for s, q := range strands {
+		cands = append(cands, I.candidates(q, s == 1, opts)...)
+	}
+	sort.SliceStable(cands, func(a, b int) bool { return cands[a].covered > cands[b].covered })
+	if len(cands) > opts.MaxCandidates {
+		cands = cands[:opts.MaxCandidates]
+	}
+
+	var alns []Alignment
+	for _, c := range cands {
+		q := strands[0]
+		if c.reverse {
+			q = strands[1]
+		}
+		a := I.extend_candidate(q, c, opts)
+		if a.Score == 0 {
+			continue
+		}
+		// loci that overlap on the same strand are one locus
+		same := false
+		for k := range alns {
+			b := &alns[k]
+			if b.SeqID == a.SeqID && b.Reverse == a.Reverse && a.Pos < b.Pos+len(read) && b.Pos < a.Pos+len(read) {
+				if a.Score > b.Score {
+					*b = a
+				}
+				same = true
+			}
+		}
+		if !same {
+			alns = append(alns, a)
+		}
+	}
+	if len(alns) == 0 {
+		return unmapped
+	}
+	sort.SliceStable(alns, func(a, b int) bool { return alns[a].Score > alns[b].Score })
+	best := alns[0]
+	if best.Score < opts.MinScore {
+		return unmapped
+	}
+	if len(alns) > 1 {
+		best.Sub = alns[1].Score
+	}
+	best.MapQ = 60 * (best.Score - best.Sub) / best.Score
+	return best
+}
+
+//-----------------------------------------------------------------------------
+// Seeds q, which is the read or its reverse complement, and clusters the
+// forward-strand occurrences of the seeds into candidate loci.  The seeds
+// of a locus lie on diagonals at most Band apart in all, so that seeds
+// spread over a repeat give several loci rather than one wide band.
+func (I *IndexC) candidates(q []byte, reverse bool, opts *AlignOptions) []candidate {
+	type seed_hit struct {
+		qs, qe    int
+		seq, diag int
+	}
+	var hits []seed_hit
+	add := func(qs, qe int, c Cursor) {
+		if c.Empty() || c.Size() > opts.MaxOcc {
+			return
+		}
+		for _, h := range c.Positions() {
+			if !h.Reverse {
+				hits = append(hits, seed_hit{qs, qe, h.SeqID, h.Offset - qs})
+			}
+		}
+	}
+	if opts.SeedLen > 0 {
+		for qs := 0; qs < len(q); qs += opts.SeedLen {
+			if qs+opts.SeedLen > len(q) {
+				qs = len(q) - opts.SeedLen
+				if qs < 0 {
+					break
+				}
+			}
+			add(qs, qs+opts.SeedLen, I.search(q[qs:qs+opts.SeedLen]))
+			if qs+opts.SeedLen == len(q) {
+				break
+			}
+		}
+	} else {
+		smems, _ := I.SMEMs(q, opts.MinSeedLen)
+		for _, m := range smems {
+			add(m.Start, m.End, m.Cursor)
+		}
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].seq != hits[b].seq {
+			return hits[a].seq < hits[b].seq
+		}
+		return hits[a].diag < hits[b].diag
+	})
+	var cands []candidate
+	for k := 0; k < len(hits); {
+		c := candidate{seq: hits[k].seq, reverse: reverse, lo: hits[k].diag, hi: hits[k].diag}
+		j := k + 1
+		for j < len(hits) && hits[j].seq == c.seq && hits[j].diag-c.lo <= opts.Band {
+			c.hi = hits[j].diag
+			j++
+		}
+		// read bases covered by the seeds of the cluster
+		spans := hits[k:j]
+		sort.Slice(spans, func(a, b int) bool { return spans[a].qs < spans[b].qs })
+		end := 0
+		for _, h := range spans {
+			if h.qs > end {
+				end = h.qs
+			}
+			if h.qe > end {
+				c.covered += h.qe - end
+				end = h.qe
+			}
+		}
+		cands = append(cands, c)
+		k = j
+	}
+	return cands
+}
+
+//-----------------------------------------------------------------------------
+// Aligns q to the text around candidate c with banded Smith-Waterman.
+// Returns an Alignment with a zero score if nothing aligns.
+func (I *IndexC) extend_candidate(q []byte, c candidate, opts *AlignOptions) Alignment {
+	n := int(I.LENS[c.seq])
+	ws, we := c.lo-opts.Band, c.hi+len(q)+opts.Band
+	if ws < 0 {
+		ws = 0
+	}
+	if we > n {
+		we = n
+	}
+	if ws >= we {
+		return Alignment{}
+	}
+	base := I.starts[c.seq]
+	ref := I.extract_text(base+indexType(ws), base+indexType(we))
+	a := smith_waterman(q, ref, c.lo-ws-opts.Band, c.hi-ws+opts.Band, opts)
+	a.SeqID = c.seq
+	a.Pos += ws
+	a.Reverse = c.reverse
+	return a
+}
+
+//-----------------------------------------------------------------------------
+// Local alignment of q to ref with affine gaps (Gotoh), restricted to the
+// cells (i, j) whose diagonal j-i lies in [lo, hi].  Gaps cost GapOpen plus
+// GapExtend per base.  Pos is the offset in ref of the first aligned base,
+// and the unaligned ends of q are soft clipped.  Only the band is stored:
+// cell (i, j) is at i*w + j-i-lo, and cells outside it have H = 0 and no
+// gap, like those of row and column 0.
+func smith_waterman(q, ref []byte, lo, hi int, opts *AlignOptions) Alignment {
+	m, n := len(q), len(ref)
+	w := hi - lo + 1
+	if w < 1 {
+		return Alignment{}
+	}
+	const minus_inf = -1 << 30
+	H := make([]int, (m+1)*w)
+	E := make([]int, (m+1)*w) // gap in q: ref bases deleted
+	F := make([]int, (m+1)*w) // gap in ref: q bases inserted
+	for k := range E {
+		E[k], F[k] = minus_inf, minus_inf
+	}
+	// value of a at cell (i, j), or out if the cell is outside the band
+	get := func(a []int, i, j, out int) int {
+		if d := j - i - lo; d >= 0 && d < w {
+			return a[i*w+d]
+		}
+		return out
+	}
+	open, ext := opts.GapOpen+opts.GapExtend, opts.GapExtend
+	score := func(i, j int) int {
+		if q[i-1] == ref[j-1] {
+			return opts.Match
+		}
+		return -opts.Mismatch
+	}
+	best, bi, bj := 0, 0, 0
+	for i := 1; i <= m; i++ {
+		jlo, jhi := i+lo, i+hi
+		if jlo < 1 {
+			jlo = 1
+		}
+		if jhi > n {
+			jhi = n
+		}
+		for j := jlo; j <= jhi; j++ {
+			k := i*w + j - i - lo
+			E[k] = max_int(get(H, i, j-1, 0)-open, get(E, i, j-1, minus_inf)-ext)
+			F[k] = max_int(get(H, i-1, j, 0)-open, get(F, i-1, j, minus_inf)-ext)
+			h := max_int(0, get(H, i-1, j-1, 0)+score(i, j))
+			h = max_int(h, max_int(E[k], F[k]))
+			H[k] = h
+			if h > best {
+				best, bi, bj = h, i, j
+			}
+		}
+	}
+	if best == 0 {
+		return Alignment{}
+	}
+
+	// trace back from the best cell; state 0 is H, 1 is E and 2 is F
+	var ops []byte
+	nm := 0
+	i, j, state := bi, bj, 0
+	for i > 0 && j > 0 {
+		k := i*w + j - i - lo
+		if state == 0 {
+			if H[k] == 0 {
+				break
+			}
+			switch {
+			case H[k] == get(H, i-1, j-1, 0)+score(i, j):
+				ops = append(ops, 'M')
+				if q[i-1] != ref[j-1] {
+					nm++
+				}
+				i, j = i-1, j-1
+			case H[k] == E[k]:
+				state = 1
+			default:
+				state = 2
+			}
+			continue
+		}
+		nm++
+		if state == 1 {
+			ops = append(ops, 'D')
+			if E[k] == get(H, i, j-1, 0)-open {
+				state = 0
+			}
+			j--
+		} else {
+			ops = append(ops, 'I')
+			if F[k] == get(H, i-1, j, 0)-open {
+				state = 0
+			}
+			i--
+		}
+	}
+
+	var cigar []byte
+	if i > 0 {
+		cigar = append(strconv.AppendInt(cigar, int64(i), 10), 'S')
+	}
+	for k := len(ops) - 1; k >= 0; {
+		l := 1
+		for k-l >= 0 && ops[k-l] == ops[k] {
+			l++
+		}
+		cigar = append(strconv.AppendInt(cigar, int64(l), 10), ops[k])
+		k -= l
+	}
+	if bi < m {
+		cigar = append(strconv.AppendInt(cigar, int64(m-bi), 10), 'S')
+	}
+	return Alignment{Pos: j, Cigar: string(cigar), Score: best, NM: nm}
+}
+
+func max_int(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,259 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Score and edit distance of alignment a of q against ref, recomputed from
+// its CIGAR with the scores of opts.  Fails if the CIGAR does not cover q.
+func cigar_score(t *testing.T, q, ref []byte, a Alignment, opts AlignOptions) (int, int) {
+	i, j, score, nm := 0, a.Pos, 0, 0
+	for _, op := range regexp.MustCompile(`(\d+)([MIDS])`).FindAllStringSubmatch(a.Cigar, -1) {
+		n, _ := strconv.Atoi(op[1])
+		switch op[2] {
+		case "M":
+			for ; n > 0; n-- {
+				if q[i] == ref[j] {
+					score += opts.Match
+				} else {
+					score -= opts.Mismatch
+					nm++
+				}
+				i, j = i+1, j+1
+			}
+		case "S":
+			i += n
+		case "I", "D":
+			score -= opts.GapOpen + n*opts.GapExtend
+			nm += n
+			if op[2] == "I" {
+				i += n
+			} else {
+				j += n
+			}
+		}
+	}
+	if i != len(q) {
+		t.Fatalf("cigar %s covers %d of %d bases", a.Cigar, i, len(q))
+	}
+	return score, nm
+}
+
+//-----------------------------------------------------------------------------
+// The traceback of smith_waterman gives the CIGAR, position, score and edit
+// distance of simple alignments.
+func TestSmithWaterman(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ref := random_seq(rng, 200, "ACGT")
+	opts := AlignOptions{}
+	opts.validate()
+	other := func(c byte) byte { return "CGTA"[strings.IndexByte("ACGT", c)] }
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	mismatch := cat(ref[50:100], []byte{other(ref[100])}, ref[101:150])
+	tests := []struct {
+		q         []byte
+		cigar     string
+		pos       int
+		score, nm int
+	}{
+		{ref[50:150], "100M", 50, 100, 0},
+		{mismatch, "100M", 50, 95, 1},
+		{cat(ref[50:100], ref[103:150]), "50M3D47M", 50, 88, 3},
+		{cat(ref[50:100], []byte("AC"), ref[100:150]), "50M2I50M", 50, 92, 2},
+		{cat([]byte{other(ref[50]), other(ref[51]), other(ref[52])}, ref[53:150]), "3S97M", 53, 97, 0},
+		{cat(ref[50:147], []byte{other(ref[147]), other(ref[148])}), "97M2S", 50, 97, 0},
+	}
+	for _, test := range tests {
+		a := smith_waterman(test.q, ref, 20, 80, &opts)
+		if a.Cigar != test.cigar || a.Pos != test.pos || a.Score != test.score || a.NM != test.nm {
+			t.Fatalf("aligned as %s at %d, score %d and NM %d; want %s at %d, score %d and NM %d",
+				a.Cigar, a.Pos, a.Score, a.NM, test.cigar, test.pos, test.score, test.nm)
+		}
+		if score, nm := cigar_score(t, test.q, ref, a, opts); score != a.Score || nm != a.NM {
+			t.Fatalf("%s: score %d and NM %d, but the CIGAR gives %d and %d", a.Cigar, a.Score, a.NM, score, nm)
+		}
+	}
+	if a := smith_waterman(random_seq(rng, 5, "N"), ref, -10, 10, &opts); a.Score != 0 {
+		t.Fatalf("Ns aligned as %s", a.Cigar)
+	}
+
+	// the matrices hold the band only, so a long reference costs nothing
+	long := random_seq(rng, 1000000, "ACGT")
+	if a := smith_waterman(long[600000:600150], long, 599990, 600010, &opts); a.Cigar != "150M" || a.Pos != 600000 {
+		t.Fatalf("read aligned to a long reference as %s at %d", a.Cigar, a.Pos)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Reads simulated on both strands, with substitutions and indels away from
+// their ends, align where they come from, with a CIGAR that gives their
+// score and NM; random reads are unmapped.
+func TestAlign(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var records []Record
+	for s := 0; s < 3; s++ {
+		records = append(records, Record{fmt.Sprint("chr", s), random_seq(rng, 10000, "ACGT")})
+	}
+	for _, build := range []BuildOptions{
+		{Ratio: 8, Multiple: true},
+		{Ratio: 8, Multiple: true, Bidirectional: true},
+	} {
+		I, err := BuildFromRecords(records, build)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []AlignOptions{{}, {SeedLen: 16}} {
+			for n := 0; n < 100; n++ {
+				s, p, read := random_substring(rng, records, 150)
+				for e := rng.Intn(4); e > 0; e-- {
+					k := 30 + rng.Intn(90)
+					switch rng.Intn(3) {
+					case 0:
+						read[k] = "ACGT"[rng.Intn(4)]
+					case 1:
+						read = append(read[:k], read[k+1:]...)
+					case 2:
+						read = append(read[:k], append([]byte{'A'}, read[k:]...)...)
+					}
+				}
+				reverse := rng.Intn(2) == 1
+				q := read
+				if reverse {
+					read = ReverseComplement(read)
+				}
+				a, err := I.Align(read, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if a.SeqID != s || a.Pos != p || a.Reverse != reverse || a.MapQ != 60 || a.Sub != 0 {
+					t.Fatalf("read from %d:%d (reverse %v) aligned as %+v", s, p, reverse, a)
+				}
+				validated := opts
+				validated.validate()
+				score, nm := cigar_score(t, q, records[s].Seq, a, validated)
+				if score != a.Score || nm != a.NM {
+					t.Fatalf("%s: score %d and NM %d, but the CIGAR gives %d and %d", a.Cigar, a.Score, a.NM, score, nm)
+				}
+			}
+			for n := 0; n < 20; n++ {
+				if a, _ := I.Align(random_seq(rng, 150, "ACGT"), opts); a.SeqID != -1 {
+					t.Fatalf("random read aligned as %+v", a)
+				}
+			}
+		}
+	}
+	I, _ := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true})
+	if _, err := I.Align(records[0].Seq[:100], AlignOptions{Mismatch: -1}); err == nil {
+		t.Fatal("negative mismatch penalty accepted")
+	}
+}
+
+//-----------------------------------------------------------------------------
+// A read from a repeat gets a low mapping quality, with the score at the
+// other copy, once seeds find both copies.
+func TestAlignRepeat(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	seq := random_seq(rng, 5000, "ACGT")
+	copy(seq[3000:3200], seq[1000:1200])
+	seq[3100] = "CGTA"[strings.IndexByte("ACGT", seq[3100])]
+	I, err := BuildFromRecords([]Record{{"g", seq}}, BuildOptions{Ratio: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := I.Align(seq[1020:1170], AlignOptions{SeedLen: 16})
+	if a.Pos != 1020 || a.Score != 150 || a.Sub != 145 || a.MapQ != 60*5/150 {
+		t.Fatalf("repeat aligned as %+v", a)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Seeds spread over a tandem repeat give loci no wider than Band, and a
+// read from the repeat still aligns.
+func TestAlignTandemRepeat(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	seq := random_seq(rng, 3000, "ACGT")
+	unit := random_seq(rng, 7, "ACGT")
+	for i := 1000; i < 1600; i++ {
+		seq[i] = unit[i%len(unit)]
+	}
+	I, err := BuildFromRecords([]Record{{"g", seq}}, BuildOptions{Ratio: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := AlignOptions{SeedLen: 16}
+	opts.validate()
+	read := seq[1400:1550]
+	cands := I.candidates(read, false, &opts)
+	if len(cands) < 2 {
+		t.Fatalf("seeds in a repeat give %d loci", len(cands))
+	}
+	for _, c := range cands {
+		if c.hi-c.lo > opts.Band {
+			t.Fatalf("locus spans diagonals %d to %d", c.lo, c.hi)
+		}
+	}
+	a, _ := I.Align(read, opts)
+	if a.SeqID != 0 || a.Score != 150 || a.Cigar != "150M" || a.Pos < 1000 || a.Pos > 1450 || (a.Pos-1400)%len(unit) != 0 {
+		t.Fatalf("read from a repeat aligned as %+v", a)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// AlignReads writes a header with unique reference names and a SAM line per
+// read: forward, reverse complemented with reversed qualities, and unmapped.
+func TestAlignReads(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := []Record{
+		{"chr1 first", random_seq(rng, 3000, "ACGT")},
+		{"chr1 second", random_seq(rng, 2000, "ACGT")},
+		{"chr1_2", random_seq(rng, 1000, "ACGT")},
+	}
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qual := strings.Repeat("I", 99) + "#"
+	fastq := "@r1 extra\n" + string(records[1].Seq[500:600]) + "\n+\n" + qual + "\n" +
+		"@r2\n" + string(ReverseComplement(records[2].Seq[10:110])) + "\n+\n" + qual + "\n" +
+		"@r3\n" + string(random_seq(rng, 100, "ACGT")) + "\n+\n" + qual + "\n"
+	var out bytes.Buffer
+	if err := I.AlignReads(strings.NewReader(fastq), &out, AlignOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"@HD\tVN:1.6\tSO:unsorted",
+		"@SQ\tSN:chr1\tLN:3000",
+		"@SQ\tSN:chr1_2\tLN:2000",
+		"@SQ\tSN:chr1_2_2\tLN:1000",
+		"@PG\tID:fmic\tPN:fmic",
+		"r1\t0\tchr1_2\t501\t60\t100M\t*\t0\t0\t" + string(records[1].Seq[500:600]) + "\t" + qual + "\tNM:i:0\tAS:i:100\tXS:i:0",
+		"r2\t16\tchr1_2_2\t11\t60\t100M\t*\t0\t0\t" + string(records[2].Seq[10:110]) + "\t#" + qual[:99] + "\tNM:i:0\tAS:i:100\tXS:i:0",
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(want)+1 {
+		t.Fatalf("wrote %d lines, want %d:\n%s", len(lines), len(want)+1, out.String())
+	}
+	for k := range want {
+		if lines[k] != want[k] {
+			t.Fatalf("line %d is\n%s\nwant\n%s", k, lines[k], want[k])
+		}
+	}
+	if f := strings.Split(lines[len(want)], "\t"); f[0] != "r3" || f[1] != "4" || f[2] != "*" || f[5] != "*" {
+		t.Fatalf("unmapped read written as %s", lines[len(want)])
+	}
+	if line := I.SAMLine(&FastqRecord{Name: "", Seq: nil}, Alignment{SeqID: -1}); line != "*\t4\t*\t0\t0\t*\t*\t0\t0\t*\t*" {
+		t.Fatalf("empty record written as %s", line)
+	}
+}
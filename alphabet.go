@@ -0,0 +1,140 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// How IUPAC ambiguity codes (R, Y, S, W, K, M, B, D, H, V) are handled.
+const (
+	IUPAC_KEEP   = iota // index them as they are
+	IUPAC_TO_N          // replace them with N
+	IUPAC_RANDOM        // replace them with a random base they stand for; N in queries
+)
+
+//-----------------------------------------------------------------------------
+// Alphabet is applied to sequences when the index is built and to queries
+// when it is searched, so that both use the same symbols.
+//-----------------------------------------------------------------------------
+type Alphabet struct {
+	FoldCase bool // convert lower case letters to upper case
+	IUPAC    int  // IUPAC_KEEP, IUPAC_TO_N or IUPAC_RANDOM
+	NBreak   int  // runs of at least NBreak N's become sequence breaks; 0 disables
+	Break    byte // symbol that replaces N's in a break; default '#'
+}
+
+// Bases each IUPAC ambiguity code stands for.
+var iupac_bases = [256]string{
+	'R': "AG", 'Y': "CT", 'S': "CG", 'W': "AT", 'K': "GT", 'M': "AC",
+	'B': "CGT", 'D': "AGT", 'H': "ACT", 'V': "ACG",
+	'r': "ag", 'y': "ct", 's': "cg", 'w': "at", 'k': "gt", 'm': "ac",
+	'b': "cgt", 'd': "agt", 'h': "act", 'v': "acg",
+}
+
+//-----------------------------------------------------------------------------
+func (a *Alphabet) validate(sep, term byte) error {
+	if a.NBreak > 0 && a.Break == 0 {
+		a.Break = '#'
+	}
+	switch {
+	case a.IUPAC < IUPAC_KEEP || a.IUPAC > IUPAC_RANDOM:
+		return fmt.Errorf("Alphabet: unknown IUPAC policy %d", a.IUPAC)
+	case a.NBreak < 0:
+		return fmt.Errorf("Alphabet: NBreak must not be negative, got %d", a.NBreak)
+	case a.NBreak > 0 && (a.Break == sep || a.Break == term):
+		return fmt.Errorf("Alphabet: Break %q is the separator or terminator", a.Break)
+	case a.NBreak > 0 && (a.Break == 'N' || a.Break == 'n'):
+		return fmt.Errorf("Alphabet: Break must not be N")
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Applies case folding and the IUPAC policy to one symbol.  Random bases
+// are drawn from rng.  Queries pass a nil rng: their ambiguity codes become
+// N, so that the same query always gets the same answer.
+func (a *Alphabet) normalize(c byte, rng *rand.Rand) byte {
+	if a.FoldCase && 'a' <= c && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	if a.IUPAC != IUPAC_KEEP {
+		if bases := iupac_bases[c]; bases != "" {
+			switch {
+			case a.IUPAC == IUPAC_RANDOM && rng != nil:
+				c = bases[rng.Intn(len(bases))]
+			case 'a' <= c && c <= 'z':
+				c = 'n'
+			default:
+				c = 'N'
+			}
+		}
+	}
+	return c
+}
+
+//-----------------------------------------------------------------------------
+// Normalizes the bases just appended to SEQ, from position start on, and
+// turns runs of NBreak or more N's into breaks.  I.n_run is the length of
+// the run of N's at the end of the current sequence.
+func (I *IndexC) normalize_appended(start int) {
+	a := &I.Alphabet
+	if !a.FoldCase && a.IUPAC == IUPAC_KEEP && a.NBreak == 0 {
+		return
+	}
+	for i := start; i < len(I.SEQ); i++ {
+		I.SEQ[i] = a.normalize(I.SEQ[i], I.rng)
+		if a.NBreak == 0 {
+			continue
+		}
+		if I.SEQ[i] != 'N' && I.SEQ[i] != 'n' {
+			I.n_run = 0
+			continue
+		}
+		I.n_run++
+		if I.n_run == a.NBreak {
+			for j := i - a.NBreak + 1; j <= i; j++ {
+				I.SEQ[j] = a.Break
+			}
+		} else if I.n_run > a.NBreak {
+			I.SEQ[i] = a.Break
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Returns query normalized by the index's alphabet.  A query containing the
+// break symbol is rejected, so no match spans a break.
+func (I *IndexC) normalize_query(query []byte) ([]byte, error) {
+	a := &I.Alphabet
+	if a.NBreak > 0 {
+		for i, c := range query {
+			if c == a.Break {
+				return nil, &UnknownSymbolError{c, i}
+			}
+		}
+	}
+	if !a.FoldCase && a.IUPAC == IUPAC_KEEP {
+		return query, nil
+	}
+	q := make([]byte, len(query))
+	for i, c := range query {
+		q[i] = a.normalize(c, nil)
+	}
+	return q, nil
+}
+
+//-----------------------------------------------------------------------------
+// Turns breaks in text extracted from the index back into N's.
+func (I *IndexC) restore_breaks(text []byte) {
+	if I.Alphabet.NBreak > 0 {
+		for i, c := range text {
+			if c == I.Alphabet.Break {
+				text[i] = 'N'
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Sequences are normalized by the alphabet when the index is built, lines of
+// a FASTA record as one sequence, and breaks read back as N's.
+func TestAlphabet(t *testing.T) {
+	tests := []struct {
+		alphabet Alphabet
+		input    string // FASTA
+		text     string // indexed text
+		extract  string // first sequence, as returned by Extract
+	}{
+		{Alphabet{}, ">a\nacgtRy\n", "acgtRy$", "acgtRy"},
+		{Alphabet{FoldCase: true}, ">a\nacgtRy\n", "ACGTRY$", "ACGTRY"},
+		{Alphabet{IUPAC: IUPAC_TO_N}, ">a\nacgtRyBdN\n", "acgtNnNnN$", "acgtNnNnN"},
+		{Alphabet{FoldCase: true, IUPAC: IUPAC_TO_N}, ">a\nacgtRyBdN\n", "ACGTNNNNN$", "ACGTNNNNN"},
+		{Alphabet{NBreak: 3}, ">a\nACNNGTNNN\nnTnnn\n", "ACNNGT####T###$", "ACNNGTNNNNTNNN"},
+		{Alphabet{NBreak: 3, Break: '_'}, ">a\nACNN\nNNGT\n>b\nNNGT\n", "AC____GT|NNGT$", "ACNNNNGT"},
+		{Alphabet{NBreak: 2, IUPAC: IUPAC_TO_N}, ">a\nACRYGTNR\n", "AC##GT##$", "ACNNGTNN"},
+		{Alphabet{NBreak: 2, FoldCase: true}, ">a\nACnNgT\n", "AC##GT$", "ACNNGT"},
+	}
+	for _, test := range tests {
+		I, err := BuildFromReader(strings.NewReader(test.input), BuildOptions{Ratio: 1, Multiple: true, Alphabet: test.alphabet})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(I.SEQ) != test.text {
+			t.Fatalf("%+v: indexed %s, want %s", test.alphabet, I.SEQ, test.text)
+		}
+		if b, err := I.Extract(0, 0, int(I.LENS[0])); err != nil || string(b) != test.extract {
+			t.Fatalf("%+v: extracted %s, error %v; want %s", test.alphabet, b, err, test.extract)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Queries are normalized like the text: with IUPAC_RANDOM, ambiguity codes
+// are random bases in the text but N in queries, and queries holding the
+// break symbol are rejected.
+func TestAlphabetQueries(t *testing.T) {
+	seq := []byte("ACGTNACRGTACYGTNNNNAC")
+	I, err := BuildFromBytes(seq, BuildOptions{Ratio: 1, Alphabet: Alphabet{FoldCase: true, IUPAC: IUPAC_RANDOM, NBreak: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range seq {
+		switch bases := iupac_bases[c]; {
+		case bases != "" && strings.IndexByte(bases, I.SEQ[i]) < 0:
+			t.Fatalf("%c at %d became %c", c, i, I.SEQ[i])
+		case bases == "" && i >= 15 && i < 19 && I.SEQ[i] != '#':
+			t.Fatalf("N at %d became %c, not a break", i, I.SEQ[i])
+		case bases == "" && (i < 15 || i >= 19) && I.SEQ[i] != c:
+			t.Fatalf("%c at %d became %c", c, i, I.SEQ[i])
+		}
+	}
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"GTN", 1},
+		{"gtn", 1},
+		{"gtr", 1},    // R in a query is N, matching GTN
+		{"ACR", 0},    // ... and not the random base drawn for R
+		{"GTNNNN", 0}, // no match spans a break
+		{"acgt", 1},
+	}
+	for _, test := range tests {
+		for k := 0; k < 3; k++ {
+			if r, err := I.Search([]byte(test.query)); err != nil || r.Count() != test.count {
+				t.Fatalf("%s found in %d rows, error %v; want %d", test.query, r.Count(), err, test.count)
+			}
+		}
+	}
+	var unknown *UnknownSymbolError
+	if _, err := I.Search([]byte("AC#")); !errors.As(err, &unknown) || unknown.Symbol != '#' || unknown.Pos != 2 {
+		t.Fatalf("query with a break: error %v", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Alphabet options that cannot work are rejected.
+func TestAlphabetErrors(t *testing.T) {
+	tests := []struct {
+		alphabet Alphabet
+		err      string
+	}{
+		{Alphabet{IUPAC: 3}, "Alphabet: unknown IUPAC policy 3"},
+		{Alphabet{NBreak: -1}, "Alphabet: NBreak must not be negative, got -1"},
+		{Alphabet{NBreak: 2, Break: '|'}, `Alphabet: Break '|' is the separator or terminator`},
+		{Alphabet{NBreak: 2, Break: 'n'}, "Alphabet: Break must not be N"},
+	}
+	for _, test := range tests {
+		if _, err := BuildFromBytes([]byte("ACGT"), BuildOptions{Ratio: 1, Alphabet: test.alphabet}); err == nil || err.Error() != test.err {
+			t.Fatalf("%+v: error %v, want %s", test.alphabet, err, test.err)
+		}
+	}
+}
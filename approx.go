@@ -0,0 +1,146 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Substitution is a query position where an approximate match differs from
+// the query, and the text symbol found there instead.
+type Substitution struct {
+	Pos  int
+	Base byte
+}
+
+//-----------------------------------------------------------------------------
+// Match is an approximate occurrence of a query: the rows of the text that
+// match the query once the substitutions are applied.  The embedded Cursor
+// gives the Range, Size and Positions of the match.
+//-----------------------------------------------------------------------------
+type Match struct {
+	Cursor
+	Substitutions []Substitution // sorted by position
+}
+
+//-----------------------------------------------------------------------------
+// Returns every way query occurs with at most k substitutions, each with
+// its suffix array interval, fewest substitutions first.  Every Match is a
+// different string of the text, so their intervals are disjoint.  The
+// query is normalized by I.Alphabet; symbols that do not occur in the text
+// can only be matched by substitutions.  Separators, the terminator and
+// breaks are never substituted in.
+//
+// The search backtracks from the end of the query and prunes with a lower
+// bound on the substitutions needed by each prefix of the query.  See
+// SearchEdits for insertions and deletions.
+//-----------------------------------------------------------------------------
+func (I *IndexC) SearchMismatches(query []byte, k int) ([]Match, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("SearchMismatches: k must not be negative, got %d", k)
+	}
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return nil, err
+	}
+	s := &mismatch_search{idx: I, query: query, bound: I.prefix_bounds(query), symbols: I.substitutes()}
+	if len(query) > 0 {
+		s.walk(len(query)-1, I.NewCursor(), k)
+	}
+	sort.SliceStable(s.matches, func(a, b int) bool {
+		ma, mb := s.matches[a], s.matches[b]
+		if len(ma.Substitutions) != len(mb.Substitutions) {
+			return len(ma.Substitutions) < len(mb.Substitutions)
+		}
+		return ma.sp < mb.sp
+	})
+	return s.matches, nil
+}
+
+//-----------------------------------------------------------------------------
+// State of a backtracking search.
+type mismatch_search struct {
+	idx     *IndexC
+	query   []byte
+	bound   []int  // bound[i]: fewest substitutions query[0..i] can occur with
+	symbols []byte // symbols that may be substituted in
+	subs    []Substitution
+	matches []Match
+}
+
+//-----------------------------------------------------------------------------
+// Matches query[0..i] on the left of the pattern of c, with at most z more
+// substitutions.
+func (s *mismatch_search) walk(i int, c Cursor, z int) {
+	if i < 0 {
+		subs := make([]Substitution, len(s.subs))
+		for j := range s.subs {
+			subs[j] = s.subs[len(s.subs)-1-j]
+		}
+		s.matches = append(s.matches, Match{c, subs})
+		return
+	}
+	if z < s.bound[i] {
+		return
+	}
+	if next := c.extend(s.query[i]); !next.Empty() {
+		s.walk(i-1, next, z)
+	}
+	if z == 0 {
+		return
+	}
+	for _, b := range s.symbols {
+		if b == s.query[i] {
+			continue
+		}
+		if next := c.extend(b); !next.Empty() {
+			s.subs = append(s.subs, Substitution{i, b})
+			s.walk(i-1, next, z-1)
+			s.subs = s.subs[:len(s.subs)-1]
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Symbols that approximate searches may put in place of query symbols: all
+// but separators, the terminator and breaks.
+func (I *IndexC) substitutes() []byte {
+	var symbols []byte
+	for _, c := range I.SYMBOLS {
+		if b := byte(c); b != I.SEP && b != I.TERM && !(I.Alphabet.NBreak > 0 && b == I.Alphabet.Break) {
+			symbols = append(symbols, b)
+		}
+	}
+	return symbols
+}
+
+//-----------------------------------------------------------------------------
+// Lower bounds on the substitutions needed by each prefix query[0..i].  The
+// shortest substring ending at i that does not occur, query[j..i], is found
+// by backward search.  It needs a substitution, and the prefix before it
+// needs its own bound[j-1] independently.
+func (I *IndexC) prefix_bounds(query []byte) []int {
+	bound := make([]int, len(query))
+	for i := range query {
+		c := I.NewCursor()
+		j := i
+		for ; j >= 0; j-- {
+			if c = c.extend(query[j]); c.Empty() {
+				break
+			}
+		}
+		if j >= 0 {
+			bound[i] = 1
+			if j > 0 {
+				bound[i] += bound[j-1]
+			}
+		}
+		if i > 0 && bound[i-1] > bound[i] {
+			bound[i] = bound[i-1]
+		}
+	}
+	return bound
+}
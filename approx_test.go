@@ -0,0 +1,70 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// SearchMismatches finds exactly the text strings within k substitutions of
+// the query, as a scan of every record finds them, each with its
+// substitutions and fewest first.
+func TestSearchMismatches(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 4, 600)
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 200; n++ {
+		_, _, query := random_substring(rng, records, 1+rng.Intn(14))
+		for e := rng.Intn(3); e > 0; e-- {
+			query[rng.Intn(len(query))] = "ACGT"[rng.Intn(4)]
+		}
+		k := rng.Intn(3)
+		var want []string
+		for s, r := range records {
+			for p := 0; p+len(query) <= len(r.Seq); p++ {
+				d := 0
+				for j := range query {
+					if r.Seq[p+j] != query[j] {
+						d++
+					}
+				}
+				if d <= k {
+					want = append(want, fmt.Sprint(s, p, d))
+				}
+			}
+		}
+		matches, err := I.SearchMismatches(query, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for i, m := range matches {
+			if i > 0 && len(m.Substitutions) < len(matches[i-1].Substitutions) {
+				t.Fatalf("%s: matches are not sorted by substitutions", query)
+			}
+			for _, h := range m.Positions() {
+				text := hit_text(records, h, len(query))
+				for _, sub := range m.Substitutions {
+					if text[sub.Pos] != sub.Base || query[sub.Pos] == sub.Base {
+						t.Fatalf("%s: substitution %v does not turn it into %s", query, sub, text)
+					}
+				}
+				got = append(got, fmt.Sprint(h.SeqID, h.Offset, len(m.Substitutions)))
+			}
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s with %d substitutions: found %v, want %v", query, k, got, want)
+		}
+	}
+}
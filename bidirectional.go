@@ -0,0 +1,115 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+)
+
+//-----------------------------------------------------------------------------
+// BiInterval is a pattern P matched in a bidirectional index: rows
+// [Fwd, Fwd+Size) of the index start with P, and rows [Rev, Rev+Size) of
+// the index of the reversed text (REV) start with P reversed.  P can be
+// extended by a symbol on either side, in any order.
+//-----------------------------------------------------------------------------
+type BiInterval struct {
+	Fwd, Rev, Size int
+	idx            *IndexC
+}
+
+//-----------------------------------------------------------------------------
+// Returns the interval of the empty pattern, which matches every row.
+// The index must have been built with BuildOptions.Bidirectional.
+//-----------------------------------------------------------------------------
+func (I *IndexC) NewBiInterval() (BiInterval, error) {
+	if I.REV == nil {
+		return BiInterval{}, fmt.Errorf("NewBiInterval: index was built without Bidirectional")
+	}
+	return BiInterval{Fwd: 0, Rev: 0, Size: int(I.LEN), idx: I}, nil
+}
+
+// Number of occurrences of the pattern.
+func (b BiInterval) Count() int {
+	return b.Size
+}
+
+// Rows of the pattern in the forward index, as returned by Search.
+func (b BiInterval) Range() Range {
+	return Range{b.Fwd, b.Fwd + b.Size - 1}
+}
+
+//-----------------------------------------------------------------------------
+// The interval of cP.  c is normalized like a query; the interval is empty
+// if cP does not occur.
+func (b BiInterval) ExtendLeft(c byte) BiInterval {
+	if b.Size == 0 {
+		return b
+	}
+	c, ok := b.idx.query_symbol(c)
+	if !ok {
+		return BiInterval{idx: b.idx}
+	}
+	fwd, rev, size := b.idx.extend_bi(c, indexType(b.Fwd), indexType(b.Rev), indexType(b.Size))
+	return BiInterval{Fwd: int(fwd), Rev: int(rev), Size: int(size), idx: b.idx}
+}
+
+//-----------------------------------------------------------------------------
+// The interval of Pc.  c is normalized like a query; the interval is empty
+// if Pc does not occur.
+func (b BiInterval) ExtendRight(c byte) BiInterval {
+	if b.Size == 0 {
+		return b
+	}
+	c, ok := b.idx.query_symbol(c)
+	if !ok {
+		return BiInterval{idx: b.idx}
+	}
+	rev, fwd, size := b.idx.REV.extend_bi(c, indexType(b.Rev), indexType(b.Fwd), indexType(b.Size))
+	return BiInterval{Fwd: int(fwd), Rev: int(rev), Size: int(size), idx: b.idx}
+}
+
+//-----------------------------------------------------------------------------
+// Normalizes a query symbol; false if it cannot occur in the text.
+func (I *IndexC) query_symbol(c byte) (byte, bool) {
+	if I.Alphabet.NBreak > 0 && c == I.Alphabet.Break {
+		return c, false
+	}
+	c = I.Alphabet.normalize(c, nil)
+	_, ok := I.C[c]
+	return c, ok
+}
+
+//-----------------------------------------------------------------------------
+// Extends the pattern at rows [k, k+s) of I by c on the left.  l is the
+// first row of the mirrored pattern in the other index, where the rows of
+// cP come after those of bP for every symbol b < c, as the rows of P
+// reversed are sorted by the symbol that follows.
+func (I *IndexC) extend_bi(c byte, k, l, s indexType) (indexType, indexType, indexType) {
+	for _, b := range I.SYMBOLS {
+		if byte(b) >= c {
+			break
+		}
+		l += I.rank(byte(b), k+s) - I.rank(byte(b), k)
+	}
+	lo, hi := I.rank(c, k), I.rank(c, k+s)
+	return I.C[c] + lo, l, hi - lo
+}
+
+//-----------------------------------------------------------------------------
+// Builds REV, the index of the text without its terminator, reversed.  The
+// occurrence of P starting at position 0 is preceded by the terminator in
+// the BWT, and so is the mirrored occurrence in REV, which ends just before
+// it.  Only the rank backend of REV is kept.
+func (I *IndexC) build_reverse() {
+	R := &IndexC{M: I.M, SEP: I.SEP, TERM: I.TERM, Workers: I.Workers, Alphabet: I.Alphabet, backend: I.backend}
+	R.SEQ = make([]byte, I.LEN)
+	for i := indexType(0); i < I.LEN-1; i++ {
+		R.SEQ[i] = I.SEQ[I.LEN-2-i]
+	}
+	R.SEQ[I.LEN-1] = I.TERM
+	R.build()
+	R.SA, R.SEQ = nil, nil
+	I.REV = R
+}
@@ -0,0 +1,61 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"math/bits"
+)
+
+//-----------------------------------------------------------------------------
+// Bit vector with constant-time rank.  A cumulative count is kept for every
+// block of 8 words (512 bits).
+//-----------------------------------------------------------------------------
+type bitVector struct {
+	bits  []uint64
+	ranks []indexType // number of set bits before each block
+	n     indexType
+}
+
+func newBitVector(n indexType) *bitVector {
+	return &bitVector{bits: make([]uint64, (n+63)/64), n: n}
+}
+
+func (b *bitVector) set(i indexType) {
+	b.bits[i/64] |= 1 << uint(i%64)
+}
+
+func (b *bitVector) get(i indexType) bool {
+	return b.bits[i/64]&(1<<uint(i%64)) != 0
+}
+
+//-----------------------------------------------------------------------------
+// Must be called after the last set and before the first rank1.
+func (b *bitVector) build_rank() {
+	b.ranks = make([]indexType, len(b.bits)/8+1)
+	var count indexType
+	for w := range b.bits {
+		if w%8 == 0 {
+			b.ranks[w/8] = count
+		}
+		count += indexType(bits.OnesCount64(b.bits[w]))
+	}
+	if len(b.bits)%8 == 0 {
+		b.ranks[len(b.bits)/8] = count
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Number of set bits in positions [0, i).
+func (b *bitVector) rank1(i indexType) indexType {
+	w := int(i / 64)
+	count := b.ranks[w/8]
+	for k := w &^ 7; k < w; k++ {
+		count += indexType(bits.OnesCount64(b.bits[k]))
+	}
+	if r := uint(i % 64); r > 0 {
+		count += indexType(bits.OnesCount64(b.bits[w] & (1<<r - 1)))
+	}
+	return count
+}
@@ -1,14 +1,25 @@
 package fmic
 
-// indexType is the type of the indices of the sequence to be indexed.
-// If indexType is uint32, the length of sequence must be smaller than 2^32.
-// For example, uint32 can be used for the human genome.  But int64 must
-// be used a collective metagenome that is longer than 4Gbp.
+// indexType is the type of positions, rows and counts in computations.
+// They are stored in 4 bytes each if the text is shorter than 2^32, e.g.
+// for the human genome, and in 8 bytes otherwise, e.g. for a metagenome
+// longer than 4Gbp.  The width is chosen when the index is built and saved
+// with it.
 
 type indexType int64
 
+// Sequence numbers in SSA take 2, 4 or 8 bytes each, whichever is the
+// smallest that can number all sequences; the width is chosen when the
+// index is built and saved with it.
 
-// The number of sequences to be indexed together must be storable by a sequenceType
-// For example, use uint16 if there are no more than 2^16 sequences to be indexed at once.
+// Default suffix array sampling rate used by SaveCompressedIndex(3) when the
+// suffix array has not been sampled yet.  Locating a hit takes at most
+// DEFAULT_SA_RATE-1 LF steps; the sample takes 4/DEFAULT_SA_RATE bytes per base
+// (8/DEFAULT_SA_RATE for texts of 2^32 characters or more).
 
-type sequenceType uint16
+const DEFAULT_SA_RATE = 32
+
+// FindGenome and FindGenomeD extend a read until it matches at most
+// FLEX_ROWS rows, then look up the sequences of all of them.
+
+const FLEX_ROWS = 11
@@ -0,0 +1,191 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+// Cursor is the suffix array interval of a pattern matched by backward
+// search.  Start matches a single symbol and Extend adds one on the left,
+// so a pattern is matched from its last symbol to its first.  Cursors are
+// values: extending one leaves it unchanged, which makes backtracking cheap.
+//-----------------------------------------------------------------------------
+type Cursor struct {
+	sp, ep indexType // rows of the pattern; empty if sp > ep
+	t      indexType // toehold SA[ep], kept by the run-length backend; -1 otherwise
+	m      int       // length of the pattern
+	idx    *IndexC
+}
+
+//-----------------------------------------------------------------------------
+// Returns the cursor of the empty pattern, which matches every row.
+//-----------------------------------------------------------------------------
+func (I *IndexC) NewCursor() Cursor {
+	c := Cursor{sp: 0, ep: I.LEN - 1, t: -1, idx: I}
+	if rle := I.run_length(); rle != nil {
+		c.t = rle.last_sa()
+	}
+	return c
+}
+
+// The cursor of the pattern made of symbol s alone.
+func (c Cursor) Start(s byte) Cursor {
+	return c.idx.NewCursor().Extend(s)
+}
+
+//-----------------------------------------------------------------------------
+// The cursor of sP, where P is the pattern of c.  s is normalized like a
+// query; the cursor is empty if sP does not occur.
+func (c Cursor) Extend(s byte) Cursor {
+	s, ok := c.idx.query_symbol(s)
+	if !ok {
+		return Cursor{sp: 0, ep: -1, t: -1, m: c.m + 1, idx: c.idx}
+	}
+	return c.extend(s)
+}
+
+// Number of occurrences of the pattern.
+func (c Cursor) Size() int {
+	if c.sp > c.ep {
+		return 0
+	}
+	return int(c.ep - c.sp + 1)
+}
+
+// True if the pattern does not occur.
+func (c Cursor) Empty() bool {
+	return c.sp > c.ep
+}
+
+// Length of the pattern.
+func (c Cursor) Len() int {
+	return c.m
+}
+
+// Rows of the pattern, as returned by Search.
+func (c Cursor) Range() Range {
+	if c.Empty() {
+		return Range{0, -1}
+	}
+	return Range{int(c.sp), int(c.ep)}
+}
+
+//-----------------------------------------------------------------------------
+// Sorted forward-strand numbers of the sequences containing the pattern.
+// They come from SSA when it is kept, and from Positions otherwise.
+func (c Cursor) SequenceIDs() []int {
+	I := c.idx
+	if c.Empty() {
+		return []int{}
+	}
+	if !I.Multiple {
+		return []int{0}
+	}
+	seen := make(map[int]bool)
+	if I.SSA != nil {
+		for i := c.sp; i <= c.ep; i++ {
+			seen[I.forward_id(int(I.SSA.Get(int(i))))] = true
+		}
+	} else {
+		for _, h := range c.Positions() {
+			seen[h.SeqID] = true
+		}
+	}
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+//-----------------------------------------------------------------------------
+// The forward-strand number of the sequence containing every occurrence of
+// the pattern, if there is one.  Stops at the first occurrence found in
+// another sequence.
+func (c Cursor) only_sequence() (int, bool) {
+	I := c.idx
+	switch {
+	case c.Empty():
+		return -1, false
+	case !I.Multiple:
+		return 0, true
+	case I.SSA != nil:
+		id := I.forward_id(int(I.SSA.Get(int(c.sp))))
+		for i := c.sp + 1; i <= c.ep; i++ {
+			if I.forward_id(int(I.SSA.Get(int(i)))) != id {
+				return -1, false
+			}
+		}
+		return id, true
+	}
+	id, ok := -1, true
+	I.each_hit(c.sp, c.ep, c.t, c.m, func(h Hit) bool {
+		ok = id < 0 || h.SeqID == id
+		id = h.SeqID
+		return ok
+	})
+	if !ok {
+		return -1, false
+	}
+	return id, true
+}
+
+//-----------------------------------------------------------------------------
+// Occurrences of the pattern, in suffix array order, as returned by Locate.
+func (c Cursor) Positions() []Hit {
+	return c.hits(indexType(c.Size()))
+}
+
+// The first n occurrences of the pattern.
+func (c Cursor) hits(n indexType) []Hit {
+	if c.Empty() {
+		return []Hit{}
+	}
+	return c.idx.row_hits(c.sp, c.ep, c.t, n, c.m)
+}
+
+// One occurrence of a non-empty pattern, found without walking the range:
+// the first row's, or the toehold's on the run-length backend without the
+// suffix array.
+func (c Cursor) any_hit() Hit {
+	I := c.idx
+	if I.run_length() != nil && I.SA == nil {
+		return I.forward_hit(I.text_to_hit(c.t), c.m)
+	}
+	return c.hits(1)[0]
+}
+
+//-----------------------------------------------------------------------------
+// One step of backward search, for a normalized symbol s.  The run-length
+// backend also moves the toehold so that the rows can be located without
+// the suffix array.
+func (c Cursor) extend(s byte) Cursor {
+	I := c.idx
+	offset, ok := I.C[s]
+	if !ok || c.Empty() {
+		return Cursor{sp: 0, ep: -1, t: -1, m: c.m + 1, idx: I}
+	}
+	next := Cursor{sp: offset + I.rank(s, c.sp), ep: offset + I.rank(s, c.ep+1) - 1, t: c.t, m: c.m + 1, idx: I}
+	if rle := I.run_length(); rle != nil && !next.Empty() {
+		next.t = rle.toehold(s, c.sp, c.ep, c.t)
+	}
+	return next
+}
+
+//-----------------------------------------------------------------------------
+// The cursor of a normalized query; empty if the query is.
+func (I *IndexC) search(query []byte) Cursor {
+	if len(query) == 0 {
+		return Cursor{sp: 0, ep: -1, t: -1, idx: I}
+	}
+	c := I.NewCursor()
+	for i := len(query) - 1; i >= 0 && !c.Empty(); i-- {
+		c = c.extend(query[i])
+	}
+	return c
+}
@@ -0,0 +1,238 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Operations of the edit scripts of EditMatch, as in extended CIGAR.
+const (
+	EDIT_MATCH    = '=' // query base matches the text
+	EDIT_MISMATCH = 'X' // query base replaced by a text base
+	EDIT_INSERT   = 'I' // query base missing from the text
+	EDIT_DELETE   = 'D' // text base missing from the query
+)
+
+//-----------------------------------------------------------------------------
+// EditMatch is an occurrence of a query with insertions and deletions: a
+// string of the text, whose rows are given by the embedded Cursor (Len is
+// its length in the text), within Edits edits of the query.  Cigar is the
+// edit script from the first query base to the last, e.g. "12=1X30=1I7=".
+//-----------------------------------------------------------------------------
+type EditMatch struct {
+	Cursor
+	Edits int
+	Cigar string
+}
+
+//-----------------------------------------------------------------------------
+// Returns the strings of the text within k edits (substitutions, insertions
+// and deletions) of query, fewest edits first.  A string found with several
+// edit scripts, or another string with the same rows, is reported once
+// with its fewest edits.  A string is not reported if it extends one that
+// is, with as few edits, as its rows are among those of the shorter one.
+// The query is normalized by I.Alphabet.  Insertions and deletions at
+// either end of the query are not tried, nor deletions next to its first or
+// last base: they only pad a shorter match with text bases.
+//
+// Like SearchMismatches, the search backtracks from the end of the query,
+// one backward search step per text base, and prunes with the lower bounds
+// of prefix_bounds.
+//-----------------------------------------------------------------------------
+func (I *IndexC) SearchEdits(query []byte, k int) ([]EditMatch, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("SearchEdits: k must not be negative, got %d", k)
+	}
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return nil, err
+	}
+	s := &edit_search{idx: I, query: query, bound: I.prefix_bounds(query), symbols: I.substitutes(), found: make(map[Range]int)}
+	if len(query) > 0 {
+		s.walk(len(query)-1, I.NewCursor(), k)
+	}
+	sort.SliceStable(s.matches, func(a, b int) bool {
+		ma, mb := s.matches[a], s.matches[b]
+		if ma.Edits != mb.Edits {
+			return ma.Edits < mb.Edits
+		}
+		return ma.sp < mb.sp
+	})
+	return s.maximal(), nil
+}
+
+//-----------------------------------------------------------------------------
+// State of a backtracking search with edits.
+type edit_search struct {
+	idx     *IndexC
+	query   []byte
+	bound   []int  // see prefix_bounds
+	symbols []byte // symbols that may be substituted or deleted in
+	ops     []byte // edit script so far, from the last query base backwards
+	text    []byte // text symbols matched so far, backwards
+	edits   int
+	matches []EditMatch
+	found   map[Range]int // index in matches of each interval found
+}
+
+//-----------------------------------------------------------------------------
+// Matches query[0..i] on the left of the pattern of c, with at most z more
+// edits.  An insertion is never next to a deletion: together they cost
+// more than a substitution.  The first and last query bases are never
+// inserted, nor next to a deletion.
+func (s *edit_search) walk(i int, c Cursor, z int) {
+	if i < 0 {
+		if c.Len() > 0 {
+			s.report(c)
+		}
+		return
+	}
+	if z < s.bound[i] {
+		return
+	}
+	last := byte(0)
+	if len(s.ops) > 0 {
+		last = s.ops[len(s.ops)-1]
+	}
+	if next := c.extend(s.query[i]); !next.Empty() {
+		s.step(EDIT_MATCH, s.query[i], i-1, next, z)
+	}
+	if z == 0 {
+		return
+	}
+	for _, b := range s.symbols {
+		if b == s.query[i] {
+			continue
+		}
+		if next := c.extend(b); !next.Empty() {
+			s.step(EDIT_MISMATCH, b, i-1, next, z-1)
+		}
+	}
+	if last != EDIT_DELETE && last != 0 && i > 0 {
+		s.step(EDIT_INSERT, 0, i-1, c, z-1)
+	}
+	if last != EDIT_INSERT && len(s.ops) > 1 && i > 0 {
+		for _, b := range s.symbols {
+			if next := c.extend(b); !next.Empty() {
+				s.step(EDIT_DELETE, b, i, next, z-1)
+			}
+		}
+	}
+}
+
+// Applies op, which matches text symbol b unless it is an insertion, and
+// walks on.
+func (s *edit_search) step(op, b byte, i int, c Cursor, z int) {
+	s.ops = append(s.ops, op)
+	if op != EDIT_INSERT {
+		s.text = append(s.text, b)
+	}
+	if op != EDIT_MATCH {
+		s.edits++
+	}
+	s.walk(i, c, z)
+	if op != EDIT_MATCH {
+		s.edits--
+	}
+	if op != EDIT_INSERT {
+		s.text = s.text[:len(s.text)-1]
+	}
+	s.ops = s.ops[:len(s.ops)-1]
+}
+
+//-----------------------------------------------------------------------------
+// Records c, reached with the current edit script, unless its rows were
+// already found with as few edits.  The string is dropped if it is closer
+// to the query than the script says: its best alignment leaves an end of
+// the query unaligned, and the string extended by the text next to it is
+// found instead.
+func (s *edit_search) report(c Cursor) {
+	j, ok := s.found[c.Range()]
+	if ok && s.matches[j].Edits <= s.edits {
+		return
+	}
+	text := make([]byte, len(s.text))
+	for k := range s.text {
+		text[k] = s.text[len(s.text)-1-k]
+	}
+	if edit_distance(s.query, text) < s.edits {
+		return
+	}
+	m := EditMatch{Cursor: c, Edits: s.edits, Cigar: cigar(s.ops)}
+	if ok {
+		s.matches[j] = m
+		return
+	}
+	s.found[c.Range()] = len(s.matches)
+	s.matches = append(s.matches, m)
+}
+
+//-----------------------------------------------------------------------------
+// The matches, in order, without those whose rows are among the rows of
+// another match with as few edits.
+func (s *edit_search) maximal() []EditMatch {
+	out := make([]EditMatch, 0, len(s.matches))
+	for _, m := range s.matches {
+		nested := false
+		for _, o := range s.matches {
+			if o.Edits <= m.Edits && o.Range() != m.Range() && o.sp <= m.sp && m.ep <= o.ep {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+//-----------------------------------------------------------------------------
+// Edit distance between a and b, with one row of the dynamic programming
+// table.
+func edit_distance(a, b []byte) int {
+	d := make([]int, len(b)+1)
+	for j := range d {
+		d[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		diag := d[0]
+		d[0] = i
+		for j := 1; j <= len(b); j++ {
+			sub := diag
+			if a[i-1] != b[j-1] {
+				sub++
+			}
+			diag = d[j]
+			d[j] = sub
+			if d[j-1]+1 < d[j] {
+				d[j] = d[j-1] + 1
+			}
+			if diag+1 < d[j] {
+				d[j] = diag + 1
+			}
+		}
+	}
+	return d[len(b)]
+}
+
+//-----------------------------------------------------------------------------
+// Run-length encodes ops, read backwards.
+func cigar(ops []byte) string {
+	var out []byte
+	for j := len(ops) - 1; j >= 0; {
+		n := 1
+		for j-n >= 0 && ops[j-n] == ops[j] {
+			n++
+		}
+		out = strconv.AppendInt(out, int64(n), 10)
+		out = append(out, ops[j])
+		j -= n
+	}
+	return string(out)
+}
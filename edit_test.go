@@ -0,0 +1,170 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Edit distance between a and b, with the full dynamic programming table.
+func brute_distance(a, b []byte) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		for j := range d[i] {
+			switch {
+			case i == 0:
+				d[i][j] = j
+			case j == 0:
+				d[i][j] = i
+			default:
+				d[i][j] = d[i-1][j-1]
+				if a[i-1] != b[j-1] {
+					d[i][j]++
+				}
+				if d[i-1][j]+1 < d[i][j] {
+					d[i][j] = d[i-1][j] + 1
+				}
+				if d[i][j-1]+1 < d[i][j] {
+					d[i][j] = d[i][j-1] + 1
+				}
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+//-----------------------------------------------------------------------------
+// Checks that the edit script of m turns query into text, with m.Edits
+// edits.
+func check_cigar(t *testing.T, query, text []byte, m EditMatch) {
+	i, j, edits := 0, 0, 0
+	for _, op := range regexp.MustCompile(`(\d+)([=XID])`).FindAllStringSubmatch(m.Cigar, -1) {
+		n, _ := strconv.Atoi(op[1])
+		for ; n > 0; n-- {
+			switch op[2][0] {
+			case EDIT_MATCH, EDIT_MISMATCH:
+				if i >= len(query) || j >= len(text) || (query[i] == text[j]) != (op[2][0] == EDIT_MATCH) {
+					t.Fatalf("%s: cigar %s does not turn %s into %s", op[2], m.Cigar, query, text)
+				}
+				i, j = i+1, j+1
+			case EDIT_INSERT:
+				i++
+			case EDIT_DELETE:
+				j++
+			}
+			if op[2][0] != EDIT_MATCH {
+				edits++
+			}
+		}
+	}
+	if i != len(query) || j != len(text) || edits != m.Edits {
+		t.Fatalf("cigar %s does not turn %s into %s with %d edits", m.Cigar, query, text, m.Edits)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Every match is a text string at its true edit distance, with a valid
+// edit script, with no deletion next to an end; none is nested in another
+// with as few edits; and a string planted with an edit in the middle is
+// found, or a part of it that does without a padding base.
+func TestSearchEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 4, 400)
+	padded := regexp.MustCompile(`^1[=X]\d+D|D1[=X]$`)
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 4, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 300; n++ {
+		k := rng.Intn(3)
+		s, p, planted := random_substring(rng, records, 6+rng.Intn(20))
+		query := append([]byte(nil), planted...)
+		if e := 1 + rng.Intn(len(query)-2); k > 0 {
+			switch rng.Intn(3) {
+			case 0:
+				query[e] = "ACGT"[rng.Intn(4)]
+			case 1:
+				query = append(query[:e], query[e+1:]...)
+			case 2:
+				query = append(query[:e], append([]byte{'A'}, query[e:]...)...)
+			}
+		}
+		matches, err := I.SearchEdits(query, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range matches {
+			text := hit_text(records, m.Positions()[0], m.Len())
+			if d := brute_distance(query, text); d != m.Edits {
+				t.Fatalf("%s matches %s with %d edits (%s), but their distance is %d", query, text, m.Edits, m.Cigar, d)
+			}
+			check_cigar(t, query, text, m)
+			if padded.MatchString(m.Cigar) {
+				t.Fatalf("%s matches %s with a deletion next to an end (%s)", query, text, m.Cigar)
+			}
+			for _, o := range matches {
+				if o.Range() != m.Range() && o.Edits <= m.Edits && o.sp <= m.sp && m.ep <= o.ep {
+					t.Fatalf("%s: %s is nested in %s", query, m.Cigar, o.Cigar)
+				}
+			}
+		}
+		found := false
+		for _, m := range matches {
+			for _, h := range m.Positions() {
+				found = found || h.SeqID == s && p <= h.Offset && h.Offset+m.Len() <= p+len(planted)
+			}
+		}
+		if !found {
+			t.Fatalf("%s not found within %d edits of %s", planted, k, query)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Padded copies of a match are not reported.
+func TestSearchEditsNoPadding(t *testing.T) {
+	I, _ := BuildFromBytes([]byte("GGTTACC"), BuildOptions{Ratio: 2})
+	matches, _ := I.SearchEdits([]byte("TT"), 2)
+	for _, m := range matches {
+		if bytes.Contains([]byte(m.Cigar), []byte{EDIT_DELETE}) && m.Len() == 3 {
+			t.Fatalf("TT matches TTA as %s", m.Cigar)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	text := random_seq(rng, 2000, "ACGT")
+	I, _ = BuildFromBytes(text, BuildOptions{Ratio: 8})
+	matches, _ = I.SearchEdits(text[500:600], 2)
+	if len(matches) != 1 || matches[0].Cigar != "100=" {
+		for _, m := range matches {
+			t.Log(m.Cigar, m.Edits)
+		}
+		t.Fatalf("exact read gives %d matches", len(matches))
+	}
+
+	// the bases next to the read repeat its ends, so that a deletion after
+	// its first base or before its last one would give a padded copy
+	for _, p := range []int{300, 1200} {
+		text[p-1], text[p+100] = text[p], text[p+99]
+	}
+	I, _ = BuildFromBytes(text, BuildOptions{Ratio: 8})
+	for _, p := range []int{300, 1200} {
+		for k := 0; k <= 2; k++ {
+			matches, _ = I.SearchEdits(text[p:p+100], k)
+			if len(matches) != 1 || matches[0].Cigar != "100=" {
+				for _, m := range matches {
+					t.Log(m.Cigar, m.Edits)
+				}
+				t.Fatalf("exact read at %d gives %d matches within %d edits", p, len(matches), k)
+			}
+		}
+	}
+}
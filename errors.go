@@ -0,0 +1,53 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+)
+
+//-----------------------------------------------------------------------------
+// UnknownSymbolError is returned when a query contains a symbol that does
+// not occur in the indexed text.  Pos is the position of the symbol in the
+// query.
+//-----------------------------------------------------------------------------
+type UnknownSymbolError struct {
+	Symbol byte
+	Pos    int
+}
+
+func (e *UnknownSymbolError) Error() string {
+	return fmt.Sprintf("fmic: unknown symbol %q at position %d", e.Symbol, e.Pos)
+}
+
+//-----------------------------------------------------------------------------
+// CorruptIndexError is returned by Load when a file of a saved index is
+// missing, truncated or malformed.
+//-----------------------------------------------------------------------------
+type CorruptIndexError struct {
+	File string
+	Err  error
+}
+
+func (e *CorruptIndexError) Error() string {
+	return fmt.Sprintf("fmic: corrupt index file %s: %v", e.File, e.Err)
+}
+
+func (e *CorruptIndexError) Unwrap() error {
+	return e.Err
+}
+
+//-----------------------------------------------------------------------------
+// VersionError is returned by Load when an index was saved in a format newer
+// than this package supports.
+//-----------------------------------------------------------------------------
+type VersionError struct {
+	Found     int
+	Supported int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("fmic: index format version %d is newer than supported version %d", e.Found, e.Supported)
+}
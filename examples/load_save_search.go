@@ -35,7 +35,7 @@ func main() {
 	// uncompressed_idx.Show()
 
 	fmt.Println("======TEST SEARCH")
-	var x, y, x1, y1 int
+	var x1, y1 int
 	for i := 0; i < 100000; i++ {
 		a := rand.Int63n(int64(saved_idx.LEN))
 		b := rand.Int63n(int64(saved_idx.LEN))
@@ -45,11 +45,14 @@ func main() {
 			}
 			seq := fmi.SEQ[a:b]
 			fmt.Printf("%d %d %d %s\n", i, a, b, string(seq))
-			x, y = saved_idx.Search(seq)
+			r, err := saved_idx.Search(seq)
+			if err != nil {
+				panic(err)
+			}
 			x1, y1, _ = uncompressed_idx.Search(seq)
 			// fmt.Println(x,y,z, x==x1, y==y1, z==z1)
-			if x != x1 || y != y1 {
-				fmt.Println("Panic:", i, a, b, "\t", x, saved_idx.SA[x], y, "\t", x1, uncompressed_idx.SA[x1], y1, string(seq))
+			if r.Sp != x1 || r.Ep != y1 {
+				fmt.Println("Panic:", i, a, b, "\t", r.Sp, saved_idx.SA.Get(r.Sp), r.Ep, "\t", x1, uncompressed_idx.SA[x1], y1, string(seq))
 				panic("Something is wrong")
 			}
 			if i%10000 == 0 {
@@ -0,0 +1,134 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Width of sequence lines written by Reconstruct.
+const FASTA_LINE_WIDTH = 60
+
+//-----------------------------------------------------------------------------
+// Returns bases [start, end) of sequence seqID, with breaks (see Alphabet)
+// turned back into N's.  If SEQ was not kept, the
+// bases are rebuilt from the BWT by LF-stepping from the nearest sampled
+// inverse suffix array entry following end (see SampleSuffixArray).
+// Returns an error if seqID or the coordinates are out of range.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Extract(seqID, start, end int) ([]byte, error) {
+	if seqID < 0 || seqID >= len(I.LENS) {
+		return nil, fmt.Errorf("Extract: sequence id %d out of range", seqID)
+	}
+	if start < 0 || start > end || indexType(end) > I.LENS[seqID] {
+		return nil, fmt.Errorf("Extract: [%d, %d) out of range for sequence %d of length %d", start, end, seqID, I.LENS[seqID])
+	}
+	a := I.starts[seqID] + indexType(start)
+	seq := I.extract_text(a, a+indexType(end-start))
+	I.restore_breaks(seq)
+	return seq, nil
+}
+
+//-----------------------------------------------------------------------------
+// Writes the indexed sequences back out in FASTA format, with headers taken
+// from GENOME_ID.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Reconstruct(w io.Writer) error {
+	var text []byte
+	if I.SEQ == nil && I.ISA_SAMPLE == nil {
+		// Without samples every extraction walks from the end of the text,
+		// so rebuild the whole text in a single walk.
+		text = I.text()
+		I.restore_breaks(text)
+	}
+	bw := bufio.NewWriter(w)
+	chunk := indexType(FASTA_LINE_WIDTH << 14)
+	for s := range I.GENOME_ID {
+		if _, err := bw.WriteString(">" + I.GENOME_ID[s] + "\n"); err != nil {
+			return err
+		}
+		for a := indexType(0); a < I.LENS[s]; a += chunk {
+			b := a + chunk
+			if b > I.LENS[s] {
+				b = I.LENS[s]
+			}
+			var seq []byte
+			if text != nil {
+				seq = text[I.starts[s]+a : I.starts[s]+b]
+			} else {
+				seq = I.extract_text(I.starts[s]+a, I.starts[s]+b)
+				I.restore_breaks(seq)
+			}
+			for len(seq) > 0 {
+				n := FASTA_LINE_WIDTH
+				if n > len(seq) {
+					n = len(seq)
+				}
+				bw.Write(seq[:n])
+				if err := bw.WriteByte('\n'); err != nil {
+					return err
+				}
+				seq = seq[n:]
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+//-----------------------------------------------------------------------------
+// The concatenated text, rebuilt from the BWT if SEQ was not kept.
+func (I *IndexC) text() []byte {
+	if I.SEQ != nil {
+		return I.SEQ
+	}
+	return I.extract_text(0, I.LEN)
+}
+
+//-----------------------------------------------------------------------------
+// Returns text[a:b] of the concatenated text.
+func (I *IndexC) extract_text(a, b indexType) []byte {
+	out := make([]byte, b-a)
+	if I.SEQ != nil {
+		copy(out, I.SEQ[a:b])
+		return out
+	}
+	if a == b {
+		return out
+	}
+	// Start from the row of suffix p, the first sampled position at or after
+	// b.  The row of suffix 0 (END_POS) stands in for position LEN.
+	p, row := I.LEN, I.END_POS
+	if I.ISA_SAMPLE != nil {
+		rate := indexType(I.SA_RATE)
+		if q := (b + rate - 1) / rate * rate; q < I.LEN {
+			p, row = q, at(I.ISA_SAMPLE, q/rate)
+		}
+	}
+	// BWT[row] is the character preceding suffix p.
+	for ; p > a; p-- {
+		if p <= b {
+			out[p-1-a] = I.bwt.access(row)
+		}
+		row = I.lf(row)
+	}
+	return out
+}
+
+//-----------------------------------------------------------------------------
+// Builds the inverse suffix array sample from the suffix array sample:
+// ISA_SAMPLE[k] is the row of the suffix starting at k*SA_RATE.
+func (I *IndexC) sample_inverse() {
+	rate := indexType(I.SA_RATE)
+	I.ISA_SAMPLE = newUintArray(I.WIDTH, indexType(I.SA_SAMPLE.Len()))
+	k := indexType(0)
+	for i := indexType(0); i < I.LEN; i++ {
+		if I.SA_MARK.get(i) {
+			put(I.ISA_SAMPLE, at(I.SA_SAMPLE, k)/rate, i)
+			k++
+		}
+	}
+}
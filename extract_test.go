@@ -0,0 +1,84 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Extract and Reconstruct give back the records, breaks included, from SEQ,
+// from the BWT with and without inverse suffix array samples, and from a
+// saved index.  Out of range arguments are errors.
+func TestExtract(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 3, 500)
+	copy(records[1].Seq[100:], "NNNNNNNN")
+	records = append(records, Record{"short", []byte("ACGTN")})
+	var fasta bytes.Buffer
+	for _, r := range records {
+		fasta.WriteString(">" + r.Name + "\n")
+		for i := 0; i < len(r.Seq); i += FASTA_LINE_WIDTH {
+			fasta.Write(r.Seq[i:min_int(i+FASTA_LINE_WIDTH, len(r.Seq))])
+			fasta.WriteByte('\n')
+		}
+	}
+
+	opts := BuildOptions{Ratio: 4, Multiple: true, SARate: 8, Alphabet: Alphabet{NBreak: 4}}
+	I, err := BuildFromRecords(records, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := I.Save(dir, 3); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	no_seq, _ := BuildFromRecords(records, opts)
+	no_seq.SEQ = nil
+	opts.SARate = 0
+	no_samples, _ := BuildFromRecords(records, opts)
+	no_samples.SEQ, no_samples.SA = nil, nil
+
+	for name, X := range map[string]*IndexC{"SEQ": I, "ISA samples": no_seq, "no samples": no_samples, "saved": saved} {
+		for s, r := range records {
+			for n := 0; n < 20; n++ {
+				a, b := rng.Intn(len(r.Seq)+1), rng.Intn(len(r.Seq)+1)
+				if n == 0 {
+					a, b = 0, len(r.Seq)
+				}
+				if a > b {
+					a, b = b, a
+				}
+				seq, err := X.Extract(s, a, b)
+				if err != nil || !bytes.Equal(seq, r.Seq[a:b]) {
+					t.Fatalf("%s: sequence %d [%d, %d) extracted as %s, %v", name, s, a, b, seq, err)
+				}
+			}
+		}
+		var out strings.Builder
+		if err := X.Reconstruct(&out); err != nil || out.String() != fasta.String() {
+			t.Fatalf("%s: reconstructed\n%s%v", name, out.String(), err)
+		}
+	}
+	for _, bad := range [][3]int{{-1, 0, 1}, {len(records), 0, 1}, {0, -1, 2}, {0, 3, 2}, {3, 0, 6}} {
+		if seq, err := I.Extract(bad[0], bad[1], bad[2]); err == nil {
+			t.Fatalf("Extract(%d, %d, %d) gave %s", bad[0], bad[1], bad[2], seq)
+		}
+	}
+}
+
+func min_int(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,175 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+//-----------------------------------------------------------------------------
+// FastaReport describes FASTA input.  In lenient mode it lists the problems
+// that were fixed; in strict mode the first problem is returned as an error.
+// FASTQ input is only checked for illegal characters, so Empty, Duplicates
+// and Unnamed stay unset.
+//-----------------------------------------------------------------------------
+type FastaReport struct {
+	Records    int          // records kept
+	Lengths    []int        // length of each kept record
+	Illegal    map[byte]int // illegal characters, with counts; removed in lenient mode
+	Empty      []string     // headers of records without bases; dropped in lenient mode
+	Duplicates []string     // headers seen more than once; renamed in lenient mode
+	Unnamed    bool         // bases before the first header; named "unnamed" in lenient mode
+}
+
+//-----------------------------------------------------------------------------
+// Parses FASTA from r, which may be compressed, and reports problems without
+// building an index.  opts supplies the separator, terminator and strictness.
+//-----------------------------------------------------------------------------
+func ValidateFasta(r io.Reader, opts BuildOptions) (*FastaReport, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	br, err := open_input(r)
+	if err != nil {
+		return nil, err
+	}
+	p := new_fasta_parser(&opts, func(string) {}, func([]byte) {})
+	err = p.parse(br)
+	return p.report, err
+}
+
+//-----------------------------------------------------------------------------
+// Appends the FASTA records read from r to the text.
+func (I *IndexC) read_fasta(r *bufio.Reader, opts *BuildOptions) error {
+	p := new_fasta_parser(opts, I.begin_sequence, I.append_sequence)
+	I.Report = p.report
+	return p.parse(r)
+}
+
+//-----------------------------------------------------------------------------
+type fasta_parser struct {
+	opts      *BuildOptions
+	format    string // "FASTA" or "FASTQ", for error messages
+	report    *FastaReport
+	line      int
+	name      string // header of the current record
+	in_record bool
+	pending   bool // the current record has no bases yet
+	names     map[string]int
+	begin     func(name string)
+	add       func(seq []byte)
+}
+
+func new_fasta_parser(opts *BuildOptions, begin func(string), add func([]byte)) *fasta_parser {
+	return &fasta_parser{
+		opts:   opts,
+		format: "FASTA",
+		report: &FastaReport{Illegal: make(map[byte]int)},
+		names:  make(map[string]int),
+		begin:  begin,
+		add:    add,
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Lines may be of any length.  A record is passed to begin only once its
+// first base is seen, so empty records can be dropped.
+func (p *fasta_parser) parse(r *bufio.Reader) error {
+	for {
+		line, err := read_line(r)
+		if err == io.EOF {
+			return p.end_record()
+		}
+		if err != nil {
+			return err
+		}
+		p.line++
+		line = bytes.Trim(line, " \t")
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '>' {
+			if err = p.end_record(); err != nil {
+				return err
+			}
+			p.name, p.in_record, p.pending = string(line[1:]), true, true
+			continue
+		}
+		if !p.in_record {
+			if p.opts.Strict {
+				return fmt.Errorf("FASTA line %d: sequence before the first header", p.line)
+			}
+			p.report.Unnamed = true
+			p.name, p.in_record, p.pending = "unnamed", true, true
+		}
+		if line, err = p.clean(line); err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if p.pending {
+			if err = p.begin_record(); err != nil {
+				return err
+			}
+		}
+		p.add(line)
+		p.report.Lengths[len(p.report.Lengths)-1] += len(line)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Removes illegal characters from a sequence line.  The line is modified in
+// place.
+func (p *fasta_parser) clean(line []byte) ([]byte, error) {
+	out := line[:0]
+	for _, c := range line {
+		if p.opts.reserved(c) || c < '!' || c > '~' {
+			if p.opts.Strict {
+				return nil, fmt.Errorf("%s line %d: illegal character %q in record %s", p.format, p.line, c, p.name)
+			}
+			p.report.Illegal[c]++
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+//-----------------------------------------------------------------------------
+func (p *fasta_parser) begin_record() error {
+	name := p.name
+	p.names[name]++
+	if p.names[name] > 1 {
+		if p.opts.Strict {
+			return fmt.Errorf("FASTA line %d: duplicate header %s", p.line, name)
+		}
+		p.report.Duplicates = append(p.report.Duplicates, name)
+		for k := p.names[p.name]; p.names[name] > 0; k++ {
+			name = fmt.Sprintf("%s_%d", p.name, k)
+		}
+		p.names[name]++
+	}
+	p.begin(name)
+	p.pending = false
+	p.report.Records++
+	p.report.Lengths = append(p.report.Lengths, 0)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+func (p *fasta_parser) end_record() error {
+	if p.in_record && p.pending {
+		if p.opts.Strict {
+			return fmt.Errorf("FASTA line %d: record %s is empty", p.line, p.name)
+		}
+		p.report.Empty = append(p.report.Empty, p.name)
+	}
+	p.in_record = false
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// ValidateFasta and Build agree on what malformed FASTA is: lenient mode
+// fixes it and describes the fixes in the report, strict mode stops at the
+// first problem with an error naming its line.
+func TestValidateFasta(t *testing.T) {
+	long := strings.Repeat("ACGT", 20000) // longer than a bufio buffer
+	tests := []struct {
+		name   string
+		input  string
+		report FastaReport // in lenient mode
+		names  []string    // sequences built in lenient mode
+		err    string      // in strict mode; "" if the input is well formed
+	}{
+		{
+			"well formed", ">a\nACGT\n  AC \n\n>b desc\nGG\n",
+			FastaReport{Records: 2, Lengths: []int{6, 2}},
+			[]string{"a", "b desc"}, "",
+		},
+		{
+			"CRLF", ">a\r\nACGT\r\n>b\r\nGG\r\n",
+			FastaReport{Records: 2, Lengths: []int{4, 2}},
+			[]string{"a", "b"}, "",
+		},
+		{
+			"duplicate headers", ">a\nAC\n>a\nGG\n>a\nTT\n>a_2\nCC\n",
+			FastaReport{Records: 4, Lengths: []int{2, 2, 2, 2}, Duplicates: []string{"a", "a", "a_2"}},
+			[]string{"a", "a_2", "a_3", "a_2_2"}, "FASTA line 4: duplicate header a",
+		},
+		{
+			"empty records", ">a\n>b\nAC\n>c\n\n",
+			FastaReport{Records: 1, Lengths: []int{2}, Empty: []string{"a", "c"}},
+			[]string{"b"}, "FASTA line 2: record a is empty",
+		},
+		{
+			"empty last record", ">a\nAC\n>c\n",
+			FastaReport{Records: 1, Lengths: []int{2}, Empty: []string{"c"}},
+			[]string{"a"}, "FASTA line 3: record c is empty",
+		},
+		{
+			"sequence before the first header", "\nAC\nGT\n>a\nTT\n",
+			FastaReport{Records: 2, Lengths: []int{4, 2}, Unnamed: true},
+			[]string{"unnamed", "a"}, "FASTA line 2: sequence before the first header",
+		},
+		{
+			"illegal characters", ">a\nAC|G$\n>b\n$|\n>c\nT\x01T\n",
+			FastaReport{Records: 2, Lengths: []int{3, 2}, Illegal: map[byte]int{'|': 2, '$': 2, 1: 1}, Empty: []string{"b"}},
+			[]string{"a", "c"}, `FASTA line 2: illegal character '|' in record a`,
+		},
+		{
+			"long lines", ">" + long + "\n" + long + "\n>b\n" + long + "|\n",
+			FastaReport{Records: 2, Lengths: []int{len(long), len(long)}, Illegal: map[byte]int{'|': 1}},
+			[]string{long, "b"}, `FASTA line 4: illegal character '|' in record b`,
+		},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		want := test.report
+		if want.Illegal == nil {
+			want.Illegal = map[byte]int{}
+		}
+		report, err := ValidateFasta(strings.NewReader(test.input), BuildOptions{Ratio: 1})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(*report, want) {
+			t.Fatalf("%s: report %+v, want %+v", test.name, *report, want)
+		}
+
+		file := path.Join(dir, "input.fa")
+		if err := ioutil.WriteFile(file, []byte(test.input), 0666); err != nil {
+			t.Fatal(err)
+		}
+		I, err := Build(BuildOptions{File: file, Ratio: 1, Multiple: true})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(*I.Report, want) || !reflect.DeepEqual(I.GENOME_ID, test.names) {
+			t.Fatalf("%s: built %v with report %+v", test.name, I.GENOME_ID, *I.Report)
+		}
+		for k, L := range want.Lengths {
+			if int(I.LENS[k]) != L {
+				t.Fatalf("%s: sequence %d has length %d, want %d", test.name, k, I.LENS[k], L)
+			}
+		}
+
+		_, err = ValidateFasta(strings.NewReader(test.input), BuildOptions{Ratio: 1, Strict: true})
+		_, build_err := Build(BuildOptions{File: file, Ratio: 1, Multiple: true, Strict: true})
+		for _, err := range []error{err, build_err} {
+			if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Fatalf("%s: strict mode error %v, want %q", test.name, err, test.err)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Build and ValidateFasta reject bad options and missing files.
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build(BuildOptions{File: path.Join(t.TempDir(), "missing.fa"), Ratio: 1}); !os.IsNotExist(err) {
+		t.Fatalf("missing file: error %v", err)
+	}
+	tests := []struct {
+		opts BuildOptions
+		err  string
+	}{
+		{BuildOptions{}, "BuildOptions: Ratio must be at least 1, got 0"},
+		{BuildOptions{Ratio: 1, SARate: -1}, "BuildOptions: SARate must not be negative, got -1"},
+		{BuildOptions{Ratio: 1, Workers: -2}, "BuildOptions: Workers must not be negative, got -2"},
+		{BuildOptions{Ratio: 1, Separator: '#', Terminator: '#'}, "BuildOptions: Separator and Terminator must differ, both are '#'"},
+		{BuildOptions{Ratio: 1, Backend: "fm"}, `BuildOptions: unknown Backend "fm"`},
+	}
+	for _, test := range tests {
+		_, err := Build(test.opts)
+		_, validate_err := ValidateFasta(strings.NewReader(">a\nAC\n"), test.opts)
+		for _, err := range []error{err, validate_err} {
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("%+v: error %v, want %s", test.opts, err, test.err)
+			}
+		}
+	}
+}
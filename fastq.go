@@ -0,0 +1,207 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// FastqRecord is a FASTQ entry.  Qual has the same length as Seq.
+type FastqRecord struct {
+	Name string
+	Seq  []byte
+	Qual []byte
+}
+
+//-----------------------------------------------------------------------------
+// FastqReader reads FASTQ records, whose sequence and quality strings may
+// span several lines.
+//-----------------------------------------------------------------------------
+type FastqReader struct {
+	r    *bufio.Reader
+	line int
+	err  error // error from opening the input, returned by Read
+}
+
+//-----------------------------------------------------------------------------
+// Gzip and BGZF compressed input is decompressed on the fly.
+//-----------------------------------------------------------------------------
+func NewFastqReader(r io.Reader) *FastqReader {
+	br, err := open_input(r)
+	return &FastqReader{r: br, err: err}
+}
+
+//-----------------------------------------------------------------------------
+// Returns the next record, or io.EOF when there are no more records.
+//-----------------------------------------------------------------------------
+func (f *FastqReader) Read() (*FastqRecord, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var header []byte
+	var err error
+	for len(header) == 0 {
+		if header, err = f.read_line(); err != nil {
+			return nil, err
+		}
+	}
+	if header[0] != '@' {
+		return nil, fmt.Errorf("FastqReader: line %d: expected '@', found %q", f.line, header)
+	}
+	rec := &FastqRecord{Name: string(header[1:])}
+
+	// sequence lines run until the '+' line
+	for {
+		line, err := f.read_line()
+		if err == io.EOF {
+			return nil, fmt.Errorf("FastqReader: line %d: record %s has no quality", f.line, rec.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(line) > 0 && line[0] == '+' {
+			break
+		}
+		rec.Seq = append(rec.Seq, line...)
+	}
+
+	// quality lines run until the quality is as long as the sequence; they
+	// may start with '@', so length is the only reliable end marker
+	for len(rec.Qual) < len(rec.Seq) {
+		line, err := f.read_line()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.Qual = append(rec.Qual, line...)
+	}
+	if len(rec.Qual) != len(rec.Seq) {
+		return nil, fmt.Errorf("FastqReader: line %d: record %s has %d bases but %d quality values",
+			f.line, rec.Name, len(rec.Seq), len(rec.Qual))
+	}
+	return rec, nil
+}
+
+//-----------------------------------------------------------------------------
+// Returns the next two records of an interleaved paired-end file.
+//-----------------------------------------------------------------------------
+func (f *FastqReader) ReadPair() (*FastqRecord, *FastqRecord, error) {
+	rec1, err := f.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	rec2, err := f.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("FastqReader: record %s has no mate", rec1.Name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return rec1, rec2, nil
+}
+
+//-----------------------------------------------------------------------------
+// Reads the next line, counting lines for error messages.
+func (f *FastqReader) read_line() ([]byte, error) {
+	line, err := read_line(f.r)
+	if err == nil {
+		f.line++
+	}
+	return line, err
+}
+
+//-----------------------------------------------------------------------------
+// Appends the FASTQ records read from r to the text, one sequence per read.
+// Illegal characters are handled as in FASTA input.
+func (I *IndexC) read_fastq(r io.Reader, opts *BuildOptions) error {
+	p := new_fasta_parser(opts, nil, nil)
+	p.format = "FASTQ"
+	I.Report = p.report
+	fq := NewFastqReader(r)
+	for {
+		rec, err := fq.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p.line, p.name = fq.line, rec.Name
+		seq, err := p.clean(rec.Seq)
+		if err != nil {
+			return err
+		}
+		I.begin_sequence(rec.Name)
+		I.append_sequence(seq)
+		p.report.Records++
+		p.report.Lengths = append(p.report.Lengths, len(seq))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Calls fn with the result of Guess for every read in r, which is in FASTQ
+// format, possibly compressed.  Empty reads are reported with seq -1.
+//-----------------------------------------------------------------------------
+func (I *IndexC) GuessReads(r io.Reader, randomized_round int, fn func(rec *FastqRecord, seq, count int)) error {
+	fq := NewFastqReader(r)
+	for {
+		rec, err := fq.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec.Seq) == 0 {
+			fn(rec, -1, 0)
+			continue
+		}
+		seq, count := I.Guess(rec.Seq, randomized_round)
+		fn(rec, seq, count)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Calls fn with the result of GuessPair for every read pair.  Mates are read
+// from r1 and r2, or alternate in r1 if r2 is nil.  Pairs with a read of at
+// most 10 bases, which GuessPair cannot handle, are reported with seq -1.
+//-----------------------------------------------------------------------------
+func (I *IndexC) GuessPairs(r1, r2 io.Reader, randomized_round, maxInsert int, fn func(rec1, rec2 *FastqRecord, seq int)) error {
+	fq1 := NewFastqReader(r1)
+	var fq2 *FastqReader
+	if r2 != nil {
+		fq2 = NewFastqReader(r2)
+	}
+	for {
+		var rec1, rec2 *FastqRecord
+		var err error
+		if fq2 == nil {
+			rec1, rec2, err = fq1.ReadPair()
+		} else if rec1, err = fq1.Read(); err == nil {
+			if rec2, err = fq2.Read(); err == io.EOF {
+				err = fmt.Errorf("GuessPairs: record %s has no mate", rec1.Name)
+			}
+		} else if err == io.EOF {
+			if _, err2 := fq2.Read(); err2 != io.EOF {
+				err = fmt.Errorf("GuessPairs: second file has more records than the first")
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec1.Seq) <= 10 || len(rec2.Seq) <= 10 {
+			fn(rec1, rec2, -1)
+			continue
+		}
+		fn(rec1, rec2, I.GuessPair(rec1.Seq, rec2.Seq, randomized_round, maxInsert))
+	}
+}
@@ -0,0 +1,180 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Reads every record of a FASTQ string.
+func read_all_fastq(t *testing.T, input string) []FastqRecord {
+	var records []FastqRecord
+	fq := NewFastqReader(strings.NewReader(input))
+	for {
+		rec, err := fq.Read()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, *rec)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Read skips blank lines between records and joins sequence and quality
+// lines; quality lines starting with '@' or '+' are quality, not headers.
+func TestFastqReader(t *testing.T) {
+	input := "\n\r\n@r1 first\nACGT\nTT\n+\nIIII\n@I\n" +
+		"@r2\nGGC\n+r2\n@@@\n\n" +
+		"@r3\nA\nC\n+\n+\n@\n"
+	want := []FastqRecord{
+		{"r1 first", []byte("ACGTTT"), []byte("IIII@I")},
+		{"r2", []byte("GGC"), []byte("@@@")},
+		{"r3", []byte("AC"), []byte("+@")},
+	}
+	if records := read_all_fastq(t, input); !reflect.DeepEqual(records, want) {
+		t.Fatalf("read %q, want %q", records, want)
+	}
+
+	tests := []struct {
+		input, err string
+	}{
+		{"r1\nACGT\n+\nIIII\n", `FastqReader: line 1: expected '@', found "r1"`},
+		{"@r1\nACGT\n", "FastqReader: line 2: record r1 has no quality"},
+		{"@r1\nACGT\n+\nII\n", "FastqReader: line 4: record r1 has 4 bases but 2 quality values"},
+		{"@r1\nAC\n+\nIII\n", "FastqReader: line 4: record r1 has 2 bases but 3 quality values"},
+		{"@r1\nAC\n+\nII\n\nACGT\n", `FastqReader: line 6: expected '@', found "ACGT"`},
+	}
+	for _, test := range tests {
+		fq := NewFastqReader(strings.NewReader(test.input))
+		var err error
+		for err == nil {
+			_, err = fq.Read()
+		}
+		if err == io.EOF || err.Error() != test.err {
+			t.Fatalf("%q: error %v, want %s", test.input, err, test.err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// ReadPair returns records two at a time and fails on an odd record.
+func TestFastqReadPair(t *testing.T) {
+	fq := NewFastqReader(strings.NewReader("@a/1\nAC\n+\nII\n@a/2\nGT\n+\nII\n@b/1\nAA\n+\nII\n"))
+	rec1, rec2, err := fq.ReadPair()
+	if err != nil || rec1.Name != "a/1" || rec2.Name != "a/2" {
+		t.Fatalf("read %v and %v, error %v", rec1, rec2, err)
+	}
+	if _, _, err = fq.ReadPair(); err == nil || err.Error() != "FastqReader: record b/1 has no mate" {
+		t.Fatalf("error %v for a missing mate", err)
+	}
+	if _, _, err = fq.ReadPair(); err != io.EOF {
+		t.Fatalf("error %v at the end of the input", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// GuessPairs reads mates from one interleaved file or from two files, and
+// fails when either file runs out of records first.
+func TestGuessPairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var records []Record
+	for s := 0; s < 3; s++ {
+		records = append(records, Record{fmt.Sprint("chr", s), random_seq(rng, 2000, "ACGT")})
+	}
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastq := func(name string, seq []byte) string {
+		return "@" + name + "\n" + string(seq) + "\n+\n" + strings.Repeat("I", len(seq)) + "\n"
+	}
+	var r1, r2, interleaved string
+	for k := 0; k < 5; k++ {
+		p := 100 * k
+		m1 := records[1].Seq[p : p+50]
+		m2 := records[1].Seq[p+200 : p+250]
+		r1 += fastq("r/1", m1)
+		r2 += fastq("r/2", m2)
+		interleaved += fastq("r/1", m1) + fastq("r/2", m2)
+	}
+	short := fastq("s/1", records[0].Seq[:10]) + fastq("s/2", records[0].Seq[:50])
+
+	count := func(r1, r2 string) (int, int, error) {
+		n, found := 0, 0
+		var second io.Reader
+		if r2 != "" {
+			second = strings.NewReader(r2)
+		}
+		err := I.GuessPairs(strings.NewReader(r1), second, 10, 500, func(rec1, rec2 *FastqRecord, seq int) {
+			n++
+			if seq == 1 {
+				found++
+			}
+		})
+		return n, found, err
+	}
+	for _, in := range [][2]string{{r1, r2}, {interleaved, ""}} {
+		if n, found, err := count(in[0], in[1]); err != nil || n != 5 || found != 5 {
+			t.Fatalf("guessed %d of %d pairs, error %v", found, n, err)
+		}
+	}
+	if n, found, err := count(short, ""); err != nil || n != 1 || found != 0 {
+		t.Fatalf("pair with a 10-base mate: %d pairs, %d guessed, error %v", n, found, err)
+	}
+
+	tests := []struct {
+		r1, r2, err string
+	}{
+		{r1 + fastq("x/1", records[2].Seq[:50]), r2, "GuessPairs: record x/1 has no mate"},
+		{r1, r2 + fastq("x/2", records[2].Seq[:50]), "GuessPairs: second file has more records than the first"},
+		{interleaved + fastq("x/1", records[2].Seq[:50]), "", "FastqReader: record x/1 has no mate"},
+	}
+	for _, test := range tests {
+		if n, _, err := count(test.r1, test.r2); err == nil || err.Error() != test.err || n != 5 {
+			t.Fatalf("%d pairs, error %v; want 5 pairs and %s", n, err, test.err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// FASTQ input, even after blank lines, is read as one sequence per read;
+// reserved symbols are removed and reported, or rejected in strict mode.
+func TestBuildFromFastq(t *testing.T) {
+	input := "\r\n\n@r1\nAC$G\nT\n+\nIIIII\n@r2\nGGA\n+\n@@@\n"
+	I, err := BuildFromReader(strings.NewReader(input), BuildOptions{Ratio: 1, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(I.SEQ) != "ACGT|GGA$" || !reflect.DeepEqual(I.GENOME_ID, []string{"r1", "r2"}) {
+		t.Fatalf("text %s with sequences %v", I.SEQ, I.GENOME_ID)
+	}
+	want := &FastaReport{Records: 2, Lengths: []int{4, 3}, Illegal: map[byte]int{'$': 1}}
+	if !reflect.DeepEqual(I.Report, want) {
+		t.Fatalf("report %+v, want %+v", I.Report, want)
+	}
+	if r, _ := I.Search([]byte("GGA")); r.Count() != 1 {
+		t.Fatalf("GGA found in %d rows", r.Count())
+	}
+
+	_, err = BuildFromReader(strings.NewReader(input), BuildOptions{Ratio: 1, Multiple: true, Strict: true})
+	if err == nil || err.Error() != `FASTQ line 7: illegal character '$' in record r1` {
+		t.Fatalf("strict mode: error %v", err)
+	}
+
+	// blank lines before FASTA do not make it FASTQ
+	I, err = BuildFromReader(strings.NewReader("\n\n>s\nACGT\n"), BuildOptions{Ratio: 1, Multiple: true})
+	if err != nil || string(I.SEQ) != "ACGT$" || I.Report.Records != 1 {
+		t.Fatalf("FASTA after blank lines read as %s, error %v", I.SEQ, err)
+	}
+}
@@ -6,38 +6,62 @@ package fmic
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
-	"math"
+	"io"
 	"math/rand"
 	"os"
 	"sort"
+	"sync"
 )
 
 //-----------------------------------------------------------------------------
-// Global variables: sequence (SEQ), suffix array (SA), BWT, FM index (C, OCC)
+// Global variables: sequence (SEQ), suffix array (SA), FM index (C, and the
+// BWT with its occurence counts)
 //-----------------------------------------------------------------------------
 
 type IndexC struct {
 	SEQ []byte
-	BWT []byte
-	SA  []indexType          // suffix array
-	SSA []sequenceType       // SSA[i] stores the sequence containing position SA[i]
-	C   map[byte]indexType   // count table
-	OCC map[byte][]indexType // occurence table
+	SA  UintArray // suffix array
+	SSA UintArray // SSA[i] stores the sequence containing position SA[i]
+
+	SA_SAMPLE  UintArray          // SA[i] for rows marked in SA_MARK
+	SA_MARK    *bitVector         // rows i where SA[i] is a multiple of SA_RATE
+	SA_RATE    int                // suffix array sampling rate; 0 if not sampled
+	ISA_SAMPLE UintArray          // ISA_SAMPLE[k] is the row of the suffix at k*SA_RATE
+	C          map[byte]indexType // count table
+	bwt        rank_backend       // BWT with its occurence counts
 
 	END_POS indexType          // position of "$" in the text
 	SYMBOLS []int              // sorted symbols
 	EP      map[byte]indexType // ending row/position of each symbol
 
-	LEN        indexType
-	LENS       []indexType
-	GENOME_ID  []string
-	OCC_SIZE   indexType
-	Freq       map[byte]indexType // Frequency of each symbol
-	M          int                // Compression ratio
-	Multiple   bool               // True if the input contains multiple sequences
-	input_file string
+	LEN               indexType
+	WIDTH             int // bytes per entry of SA, its samples and occurence counts: 4 or 8
+	LENS              []indexType
+	GENOME_ID         []string
+	OCC_SIZE          indexType          // number of occurence checkpoints
+	RUNS              indexType          // number of runs of equal symbols in the BWT
+	Freq              map[byte]indexType // Frequency of each symbol
+	M                 int                // Compression ratio
+	Multiple          bool               // True if the input contains multiple sequences
+	SEP               byte               // separator placed between sequences
+	TERM              byte               // terminator placed at the end of the text
+	Workers           int                // goroutines used to build and save; 0 means one per CPU
+	Report            *FastaReport       // problems found in FASTA or FASTQ input; nil for other inputs
+	input_file        string
+	output_dir        string
+	Alphabet          Alphabet    // normalization of sequences and queries
+	ReverseComplement bool        // reverse strands are indexed after the forward ones
+	rng               *rand.Rand  // draws IUPAC_RANDOM bases at build time
+	n_run             int         // N's at the end of the sequence being read
+	starts            []indexType // starting position of each sequence in SEQ
+	backend           string      // rank backend requested at build time; "" picks one
+	REV               *IndexC     // index of the reversed text, for BiInterval; nil unless Bidirectional
+	seq_rows          *bitVector  // rows of sequence seq_rows_id, kept by MatchingStatisticsIn
+	seq_rows_id       int
+	seq_rows_mu       sync.Mutex
+	sam_names         []string // unique SAM reference names, set by sam_name
+	sam_once          sync.Once
 }
 
 //-----------------------------------------------------------------------------
@@ -46,155 +70,271 @@ type IndexC struct {
 // compression ratio >=1
 //-----------------------------------------------------------------------------
 func CompressedIndex(file string, multiple bool, compression_ratio int) *IndexC {
+	I, err := Build(BuildOptions{File: file, Multiple: multiple, Ratio: compression_ratio})
+	check_for_error(err)
+	return I
+}
+
+//-----------------------------------------------------------------------------
+// Build FM index as specified by opts.  Returns an error instead of
+// panicking.
+//-----------------------------------------------------------------------------
+func Build(opts BuildOptions) (*IndexC, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return build_index(opts, func(I *IndexC) error {
+		return I.read_input(f, &opts)
+	})
+}
+
+// Record is a named sequence.
+type Record struct {
+	Name string
+	Seq  []byte
+}
+
+//-----------------------------------------------------------------------------
+// Build FM index from FASTA or FASTQ read from r, which may be gzip or BGZF
+// compressed.  opts.File is ignored; set opts.OutputDir to use
+// SaveCompressedIndex.
+//-----------------------------------------------------------------------------
+func BuildFromReader(r io.Reader, opts BuildOptions) (*IndexC, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return build_index(opts, func(I *IndexC) error {
+		return I.read_input(r, &opts)
+	})
+}
+
+//-----------------------------------------------------------------------------
+// Build FM index from in-memory sequences.  opts.File is ignored; set
+// opts.OutputDir to use SaveCompressedIndex.  Sequences must not contain
+// the separator, the terminator or the break symbol.
+//-----------------------------------------------------------------------------
+func BuildFromRecords(records []Record, opts BuildOptions) (*IndexC, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		for _, c := range rec.Seq {
+			if opts.reserved(c) {
+				return nil, fmt.Errorf("BuildFromRecords: record %q contains reserved symbol %q", rec.Name, c)
+			}
+		}
+	}
+	return build_index(opts, func(I *IndexC) error {
+		for _, rec := range records {
+			I.begin_sequence(rec.Name)
+			I.append_sequence(rec.Seq)
+		}
+		return nil
+	})
+}
+
+//-----------------------------------------------------------------------------
+// Build FM index from a single unnamed sequence.
+//-----------------------------------------------------------------------------
+func BuildFromBytes(seq []byte, opts BuildOptions) (*IndexC, error) {
+	return BuildFromRecords([]Record{{"", seq}}, opts)
+}
+
+//-----------------------------------------------------------------------------
+// Sets up an index from validated options, lets read add the sequences,
+// then builds the index.
+func build_index(opts BuildOptions, read func(*IndexC) error) (*IndexC, error) {
 	I := new(IndexC)
-	I.input_file = file
-	I.M = compression_ratio
-	I.Multiple = multiple
+	I.input_file = opts.File
+	I.output_dir = opts.OutputDir
+	I.M = opts.Ratio
+	I.Multiple = opts.Multiple
+	I.SEP, I.TERM = opts.Separator, opts.Terminator
+	I.Workers = opts.Workers
+	I.Alphabet = opts.Alphabet
+	I.ReverseComplement = opts.ReverseComplement
+	I.backend = opts.Backend
+	I.rng = rand.New(rand.NewSource(1))
 
 	// GET THE SEQUENCE
-	I.ReadFasta(file)
+	if err := read(I); err != nil {
+		return nil, err
+	}
+	if len(I.GENOME_ID) == 0 {
+		return nil, fmt.Errorf("Build: input contains no sequences")
+	}
+	if I.ReverseComplement {
+		I.append_reverse_complements()
+	}
+	I.SEQ = append(I.SEQ, I.TERM)
+	I.build()
+	if opts.Bidirectional {
+		I.build_reverse()
+	}
+	if opts.SARate > 0 {
+		I.SampleSuffixArray(opts.SARate)
+	}
+	return I, nil
+}
 
+//-----------------------------------------------------------------------------
+// Builds the suffix array, BWT, count and occurence tables from SEQ.
+func (I *IndexC) build() {
 	// BUILD SUFFIX ARRAY
 	I.LEN = indexType(len(I.SEQ))
-	I.OCC_SIZE = indexType(math.Ceil(float64(I.LEN/indexType(I.M)))) + 1
-	I.SA = make([]indexType, I.LEN)
-	var SID []sequenceType
-	if I.Multiple {
-		I.SSA = make([]sequenceType, I.LEN)
-		SID = make([]sequenceType, I.LEN)
+	I.OCC_SIZE = I.LEN/indexType(I.M) + 1
+	I.WIDTH = index_width(I.LEN)
+	I.SA = newUintArray(I.WIDTH, I.LEN)
+	var SID UintArray
+	if I.Multiple && I.backend != BACKEND_RLE {
+		// the run-length backend finds sequences by locating rows instead
+		width := width_for(uint64(I.n_stored()))
+		I.SSA = newUintArray(width, I.LEN)
+		SID = newUintArray(width, I.LEN)
 	}
 	SA := make([]int, I.LEN)
 	ws := &WorkSpace{}
 	ws.ComputeSuffixArray(I.SEQ, SA)
-	sid := sequenceType(0)
+	sid := uint64(0)
 	for i := range SA {
-		I.SA[i] = indexType(SA[i])
-		if I.Multiple {
-			SID[i] = sid
-			if I.SEQ[i] == '|' {
+		I.SA.Set(i, uint64(SA[i]))
+		if SID != nil {
+			SID.Set(i, sid)
+			if I.SEQ[i] == I.SEP {
 				sid++
 			}
 		}
 	}
 
 	// BUILD BWT
-	I.Freq = make(map[byte]indexType)
-	I.BWT = make([]byte, I.LEN)
-	var i indexType
-	for i = 0; i < I.LEN; i++ {
-		I.Freq[I.SEQ[i]]++
-		if I.SA[i] == 0 {
-			I.BWT[i] = I.SEQ[I.LEN-1]
-		} else {
-			I.BWT[i] = I.SEQ[I.SA[i]-1]
-		}
-		if I.BWT[i] == '$' {
-			I.END_POS = i
+	bwt := make([]byte, I.LEN)
+	freq := make([][256]indexType, I.workers())
+	parallel(I.workers(), I.LEN, func(w int, lo, hi indexType) {
+		for i := lo; i < hi; i++ {
+			freq[w][I.SEQ[i]]++
+			p := at(I.SA, i)
+			if p == 0 {
+				bwt[i] = I.SEQ[I.LEN-1]
+				I.END_POS = i
+			} else {
+				bwt[i] = I.SEQ[p-1]
+			}
+			if SID != nil {
+				I.SSA.Set(int(i), SID.Get(int(p)))
+			}
 		}
-		if I.Multiple {
-			I.SSA[i] = SID[I.SA[i]]
+	})
+	I.Freq = make(map[byte]indexType)
+	for w := range freq {
+		for c, f := range freq[w] {
+			if f > 0 {
+				I.Freq[byte(c)] += f
+			}
 		}
 	}
 
 	// BUILD COUNT AND OCCURENCE TABLE
 	I.C = make(map[byte]indexType)
-	I.OCC = make(map[byte][]indexType)
 	for c := range I.Freq {
 		I.SYMBOLS = append(I.SYMBOLS, int(c))
-		I.OCC[c] = make([]indexType, I.OCC_SIZE)
 		I.C[c] = 0
 	}
 	sort.Ints(I.SYMBOLS)
 	I.EP = make(map[byte]indexType)
-	count := make(map[byte]indexType)
-
-	for j := 1; j < len(I.SYMBOLS); j++ {
-		curr_c, prev_c := byte(I.SYMBOLS[j]), byte(I.SYMBOLS[j-1])
-		I.C[curr_c] = I.C[prev_c] + I.Freq[prev_c]
+	for j := 0; j < len(I.SYMBOLS); j++ {
+		curr_c := byte(I.SYMBOLS[j])
+		if j > 0 {
+			prev_c := byte(I.SYMBOLS[j-1])
+			I.C[curr_c] = I.C[prev_c] + I.Freq[prev_c]
+		}
 		I.EP[curr_c] = I.C[curr_c] + I.Freq[curr_c] - 1
-		count[curr_c] = 0
 	}
+	I.RUNS = count_runs(bwt)
+	I.bwt = I.new_rank(bwt, I.backend)
+	I.compute_starts()
+}
 
-	for j := 0; j < len(I.BWT); j++ {
-		count[I.BWT[j]] += 1
-		if j%I.M == 0 {
-			for symbol := range I.OCC {
-				I.OCC[symbol][int(j/I.M)] = count[symbol]
-			}
-		}
-	}
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0..pos].
+func (I *IndexC) Occurence(c byte, pos indexType) indexType {
+	return I.rank(c, pos+1)
+}
 
-	return I
+//-----------------------------------------------------------------------------
+// BWT[i], the symbol preceding the suffix at row i.
+func (I *IndexC) Access(i int) byte {
+	return I.bwt.Access(i)
 }
 
 //-----------------------------------------------------------------------------
-func (I *IndexC) Occurence(c byte, pos indexType) indexType {
-	i := indexType(pos / indexType(I.M))
-	count := I.OCC[c][i]
-	for j := i*indexType(I.M) + 1; j <= pos; j++ {
-		if I.BWT[j] == c {
-			count += 1
-		}
+// Range is an interval [Sp, Ep] of rows of the suffix array.  It is empty
+// when Sp > Ep.
+type Range struct {
+	Sp, Ep int
+}
+
+// Number of rows in the range, i.e. the number of occurrences of a query.
+func (r Range) Count() int {
+	if r.Sp > r.Ep {
+		return 0
 	}
-	return count
+	return r.Ep - r.Sp + 1
 }
 
 // -----------------------------------------------------------------------------
-// Returns starting, ending positions (sp, ep) and last-matched position (i)
-
-func (I *IndexC) Search(query []byte) (int, int) {
-	var offset indexType
-	var i int
-	start_pos := len(query) - 1
-	c := query[start_pos]
-	sp, ok := I.C[c]
-	if !ok {
-		panic("Unknown character: " + string(c))
-	}
-	ep := I.EP[c]
-	fmt.Println(string(c), sp, ep)
-	// fmt.Println(ep-sp+1, "\t", i, string(c), len(query))
-	for i = int(start_pos - 1); sp <= ep && i >= 0; i-- {
-		c = query[i]
-		offset, ok = I.C[c]
-		if !ok {
-			panic("Unknown character: " + string(c))
+// Returns the range (sp, ep) of suffixes that start with query; query occurs
+// ep-sp+1 times.  Returns an *UnknownSymbolError if query contains a symbol
+// that does not occur in the text.  The query is normalized by I.Alphabet.
+// With ReverseComplement the count includes reverse-strand occurrences.
+
+func (I *IndexC) Search(query []byte) (Range, error) {
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return Range{0, -1}, err
+	}
+	for i, c := range query {
+		if _, ok := I.C[c]; !ok {
+			return Range{0, -1}, &UnknownSymbolError{c, i}
 		}
-		sp = offset + I.Occurence(c, sp-1)
-		ep = offset + I.Occurence(c, ep) - 1
-		fmt.Println(string(c), sp, ep)
-		// fmt.Println(ep-sp+1, "\t", i, string(c), len(query))
 	}
-	return int(sp), int(ep)
+	sp, ep := I.backward_search(query)
+	return Range{int(sp), int(ep)}, nil
+}
+
+//-----------------------------------------------------------------------------
+// Returns the suffix array interval (sp, ep) of query; sp > ep if query
+// does not occur or contains an unknown character.
+
+func (I *IndexC) backward_search(query []byte) (indexType, indexType) {
+	c := I.search(query)
+	return c.sp, c.ep
 }
 
 //-----------------------------------------------------------------------------
-func (I *IndexC) flex_search(query []byte, start_pos int) map[sequenceType]indexType {
+func (I *IndexC) flex_search(query []byte, start_pos int) map[int]indexType {
 	if !I.Multiple {
-		return map[sequenceType]indexType{}
+		return map[int]indexType{}
 	}
-	var offset indexType
-	var i int
-	c := query[start_pos]
-	sp, ok := I.C[c]
-	if !ok {
-		return map[sequenceType]indexType{}
-	}
-	ep := I.EP[c]
-	for i = int(start_pos - 1); sp < ep && i >= 0 && ep-sp > 10; i-- {
-		c = query[i]
-		offset, ok = I.C[c]
-		if !ok {
-			return map[sequenceType]indexType{}
+	if _, ok := I.C[query[start_pos]]; !ok {
+		return map[int]indexType{}
+	}
+	cur := I.NewCursor().extend(query[start_pos])
+	for i := start_pos - 1; cur.Size() > FLEX_ROWS && i >= 0; i-- {
+		if _, ok := I.C[query[i]]; !ok {
+			return map[int]indexType{}
 		}
-		sp = offset + I.Occurence(c, sp-1)
-		ep = offset + I.Occurence(c, ep) - 1
-		// fmt.Println(ep-sp+1, "\t", i, string(c), len(query))
-	}
-	gid := make(map[sequenceType]indexType)
-	if (sp <= ep) && (ep-sp <= 10) {
-		for i := sp; i <= ep; i++ {
-			gid[I.SSA[i]] = I.SA[i]
+		cur = cur.extend(query[i])
+		// fmt.Println(cur.Size(), "\t", i, string(query[i]), len(query))
+	}
+	gid := make(map[int]indexType)
+	if !cur.Empty() && cur.Size() <= FLEX_ROWS {
+		for _, h := range cur.Positions() {
+			gid[h.SeqID] = indexType(h.Offset)
 		}
 	}
 	return gid
@@ -202,7 +342,12 @@ func (I *IndexC) flex_search(query []byte, start_pos int) map[sequenceType]index
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) FindGenomeD(query1 []byte, query2 []byte, maxInsert int) map[int]int {
-	var gid1, gid2 map[sequenceType]indexType
+	query1, err1 := I.normalize_query(query1)
+	query2, err2 := I.normalize_query(query2)
+	if err1 != nil || err2 != nil {
+		return map[int]int{}
+	}
+	var gid1, gid2 map[int]indexType
 	var pos int
 	max := len(query1)
 	if max > len(query2) {
@@ -229,7 +374,12 @@ func (I *IndexC) FindGenomeD(query1 []byte, query2 []byte, maxInsert int) map[in
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) FindGenome(query1 []byte, query2 []byte, randomized_round, maxInsert int) map[int]int {
-	var gid1, gid2 map[sequenceType]indexType
+	query1, err1 := I.normalize_query(query1)
+	query2, err2 := I.normalize_query(query2)
+	if err1 != nil || err2 != nil {
+		return map[int]int{}
+	}
+	var gid1, gid2 map[int]indexType
 	var pos int
 	for i := 0; i < randomized_round; i++ {
 		pos = 10 + rand.Intn(len(query1)-10)
@@ -255,10 +405,15 @@ func (I *IndexC) FindGenome(query1 []byte, query2 []byte, randomized_round, maxI
 //-----------------------------------------------------------------------------
 // Guess which sequence contains the query.
 // If randomized_round is 0, there is no randomization. The search begins at the.
+// With ReverseComplement, the query may come from either strand.
 //-----------------------------------------------------------------------------
 
 func (I *IndexC) Guess(query []byte, randomized_round int) (int, int) {
 	var seq, count int
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return -2, 0
+	}
 	// var start_pos, end_pos int
 	if randomized_round == 0 {
 		seq, count, _ = I._guess(query, len(query)-1)
@@ -279,6 +434,11 @@ func (I *IndexC) Guess(query []byte, randomized_round int) (int, int) {
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) GuessPairD(query1 []byte, query2 []byte) int {
+	query1, err1 := I.normalize_query(query1)
+	query2, err2 := I.normalize_query(query2)
+	if err1 != nil || err2 != nil {
+		return -1
+	}
 	var seq1, seq2, p1, p2 int
 	max := len(query1)
 	if max > len(query2) {
@@ -300,6 +460,11 @@ func (I *IndexC) GuessPairD(query1 []byte, query2 []byte) int {
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) GuessPair(query1 []byte, query2 []byte, randomized_round, maxInsert int) int {
+	query1, err1 := I.normalize_query(query1)
+	query2, err2 := I.normalize_query(query2)
+	if err1 != nil || err2 != nil {
+		return -1
+	}
 	var seq1, seq2, p1, p2, pos int
 	// var c1, c2 int
 	for i := 0; i < randomized_round; i++ {
@@ -325,105 +490,114 @@ func (I *IndexC) _guess(query []byte, start_pos int) (int, int, int) {
 	if !I.Multiple {
 		return 0, -1, -1
 	}
-	var offset indexType
-	var i int
-	c := query[start_pos]
-	sp, ok := I.C[c]
-	if !ok {
+	if _, ok := I.C[query[start_pos]]; !ok {
 		return -2, 0, 0
 	}
-	ep := I.EP[c]
-	// fmt.Println(ep-sp+1, "\t", i, string(c), len(query))
-	for i = int(start_pos - 1); sp < ep && i >= 0; i-- {
-		c = query[i]
-		offset, ok = I.C[c]
-		if !ok {
+	cur := I.NewCursor().extend(query[start_pos])
+	for i := start_pos - 1; cur.Size() > 1 && i >= 0; i-- {
+		if _, ok := I.C[query[i]]; !ok {
 			return -2, 0, 0
 		}
-		// if ep-sp <= 10 {
-		// 	for k := sp; k <= ep; k++ {
-		// 		fmt.Printf("%d ", I.SSA[k])
-		// 	}
-		// 	fmt.Println("[", start_pos-i, "]")
-		// }
-		sp = offset + I.Occurence(c, sp-1)
-		ep = offset + I.Occurence(c, ep) - 1
-		// fmt.Println(ep-sp+1, "\t", i, string(c), len(query))
-	}
-	if sp <= ep {
-		for j := sp + 1; j <= ep; j++ {
-			if I.SSA[j] != I.SSA[sp] {
-				return -1, int(ep - sp + 1), -1
-			}
-		}
-		return int(I.SSA[sp]), int(ep - sp + 1), int(I.SA[sp])
-	} else {
-		return -1, int(ep - sp + 1), -1
+		cur = cur.extend(query[i])
+		// fmt.Println(cur.Size(), "\t", i, string(query[i]), len(query))
+	}
+	if cur.Empty() {
+		return -1, 0, -1
+	}
+	id, ok := cur.only_sequence()
+	if !ok {
+		return -1, cur.Size(), -1
 	}
+	return id, cur.Size(), cur.any_hit().Offset
 }
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) ReadFasta(file string) {
+	if I.SEP == 0 {
+		I.SEP, I.TERM = '|', '$'
+	}
 	f, err := os.Open(file)
 	check_for_error(err)
 	defer f.Close()
+	check_for_error(I.read_fasta(bufio.NewReader(f), &BuildOptions{Separator: I.SEP, Terminator: I.TERM}))
+	I.SEQ = append(I.SEQ, I.TERM)
+}
 
-	if file[len(file)-6:] != ".fasta" {
-		panic("ReadFasta:" + file + "is not a fasta file.")
-	}
-
-	scanner := bufio.NewScanner(f)
-	byte_array := make([]byte, 0)
-	i := 0
-	cur_len := 0
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) > 0 {
-			line = bytes.Trim(line, "\n\r ")
-			if line[0] != '>' {
-				byte_array = append(byte_array, line...)
-				cur_len += len(line)
-			} else {
-				I.GENOME_ID = append(I.GENOME_ID, string(line[1:]))
-				if cur_len != 0 {
-					I.LENS = append(I.LENS, indexType(cur_len))
-				}
-				cur_len = 0
-				if len(byte_array) > 0 {
-					byte_array = append(byte_array, byte('|'))
-				}
+//-----------------------------------------------------------------------------
+// Appends the records read from r to the text.  Input whose first non-blank
+// line starts with '@' is read as FASTQ, anything else as FASTA.  Compressed
+// input is decompressed.
+func (I *IndexC) read_input(r io.Reader, opts *BuildOptions) error {
+	br, err := open_input(r)
+	if err != nil {
+		return err
+	}
+	// the first character after any blank lines tells the format
+	for n := 1; ; n++ {
+		b, err := br.Peek(n)
+		if err != nil || b[n-1] != '\n' && b[n-1] != '\r' {
+			if err == nil && b[n-1] == '@' {
+				return I.read_fastq(br, opts)
 			}
-			i++
+			break
 		}
 	}
-	I.LENS = append(I.LENS, indexType(cur_len))
-	I.SEQ = append(byte_array, byte('$'))
+	return I.read_fasta(br, opts)
+}
+
+//-----------------------------------------------------------------------------
+// Starts a new sequence in the text.
+func (I *IndexC) begin_sequence(name string) {
+	if len(I.GENOME_ID) > 0 {
+		I.SEQ = append(I.SEQ, I.SEP)
+	}
+	I.GENOME_ID = append(I.GENOME_ID, name)
+	I.LENS = append(I.LENS, 0)
+	I.n_run = 0
+}
+
+//-----------------------------------------------------------------------------
+// Appends bases to the current sequence; starts an unnamed one if needed.
+func (I *IndexC) append_sequence(seq []byte) {
+	if len(I.GENOME_ID) == 0 {
+		I.begin_sequence("")
+	}
+	start := len(I.SEQ)
+	I.SEQ = append(I.SEQ, seq...)
+	I.normalize_appended(start)
+	I.LENS[len(I.LENS)-1] += indexType(len(seq))
 }
 
 //-----------------------------------------------------------------------------
 func (I *IndexC) Show() {
+	fmt.Printf("%d runs in BWT of length %d (%s)\n", I.RUNS, I.LEN, I.bwt.Name())
 	fmt.Printf(" %6s %6s  OCC\n", "Freq", "C")
 	for i := 0; i < len(I.SYMBOLS); i++ {
 		c := byte(I.SYMBOLS[i])
-		fmt.Printf("%c%6d %6d  %d\n", c, I.Freq[c], I.C[c], I.OCC[c])
+		occ := make([]indexType, I.OCC_SIZE)
+		for k := range occ {
+			occ[k] = I.bwt.rank(c, indexType(k*I.M))
+		}
+		fmt.Printf("%c%6d %6d  %d\n", c, I.Freq[c], I.C[c], occ)
 	}
 	fmt.Printf("SA ")
-	for i := 0; i < len(I.SA); i++ {
-		fmt.Print(I.SA[i], " ")
+	for i := 0; I.SA != nil && i < I.SA.Len(); i++ {
+		fmt.Print(I.SA.Get(i), " ")
 	}
 	fmt.Printf("\nBWT ")
-	for i := 0; i < len(I.BWT); i++ {
-		fmt.Print(string(I.BWT[i]))
+	for i := indexType(0); i < I.LEN; i++ {
+		fmt.Print(string(I.bwt.access(i)))
 	}
 	fmt.Println()
 	fmt.Printf("\nSSA ")
-	for i := 0; i < len(I.SSA); i++ {
-		fmt.Printf("%d ", I.SSA[i])
+	for i := 0; I.SSA != nil && i < I.SSA.Len(); i++ {
+		fmt.Printf("%d ", I.SSA.Get(i))
 	}
 	fmt.Println()
-	fmt.Println("SEQ", string(I.SEQ))
-	for i := 0; i < len(I.SA); i++ {
-		fmt.Printf("%4d %s\n", i, I.SEQ[I.SA[i]:])
+	seq := I.text()
+	fmt.Println("SEQ", string(seq))
+	for i := 0; I.SA != nil && i < I.SA.Len(); i++ {
+		fmt.Printf("%4d %s\n", i, seq[I.SA.Get(i):])
 	}
 }
 
@@ -438,9 +612,9 @@ func (I *IndexC) Check() {
 		}
 		fmt.Printf("]\n")
 	}
-	if len(I.SEQ) > 0 {
-		seq, count := I.Search(I.SEQ[0 : len(I.SEQ)-1])
-		fmt.Println("Search for SEQ returns", seq, count)
+	if seq := I.text(); len(seq) > 0 {
+		sp, ep := I.backward_search(seq[0 : len(seq)-1])
+		fmt.Println("Search for SEQ returns", sp, ep)
 	}
 }
 
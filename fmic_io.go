@@ -6,21 +6,24 @@ package fmic
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
-	"unsafe"
 )
 
-type Symb_OCC struct {
-	Symb int
-	OCC  []indexType
-}
+// Version of the on-disk index layout written by Save.  Indexes saved before
+// versioning was introduced have no "version" file and are read as version 0.
+// Version 1 keeps the rank backend instead of the BWT and the occurence
+// table, and writes the header of "others" as one "key value" line per field.
+const FORMAT_VERSION = 1
 
 //-----------------------------------------------------------------------------
 func check_for_error(e error) {
@@ -30,26 +33,87 @@ func check_for_error(e error) {
 }
 
 //-----------------------------------------------------------------------------
-// Save the index to directory.
+// Runs functions in goroutines and keeps the first error they return.  If
+// sem is not nil, at most cap(sem) functions run at once.
+type errGroup struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+	sem chan struct{}
+}
 
-func _save_indexType(s []indexType, filename string) {
-	f, err := os.Create(filename)
-	check_for_error(err)
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	err = binary.Write(w, binary.LittleEndian, s)
-	check_for_error(err)
-	w.Flush()
+func (g *errGroup) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			g.sem <- struct{}{}
+			defer func() { <-g.sem }()
+		}
+		if err := f(); err != nil {
+			g.mu.Lock()
+			if g.err == nil {
+				g.err = err
+			}
+			g.mu.Unlock()
+		}
+	}()
 }
 
-func _save_sequenceType(s []sequenceType, filename string) {
+func (g *errGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+//-----------------------------------------------------------------------------
+// Wraps an input stream, decompressing it if it starts with the gzip magic
+// bytes.  BGZF files are gzip files made of many members, which gzip.Reader
+// reads one after the other.
+func open_input(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(gz), nil
+}
+
+//-----------------------------------------------------------------------------
+// Returns the next line without its end-of-line characters.  Lines may be
+// of any length.  Returns io.EOF only when there is no more input.
+func read_line(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return bytes.TrimRight(line, "\r\n"), err
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Save a slice of fixed-size values to a file, in little-endian order.
+
+func _save_binary(s interface{}, filename string) error {
 	f, err := os.Create(filename)
-	check_for_error(err)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
 	w := bufio.NewWriter(f)
-	err = binary.Write(w, binary.LittleEndian, s)
-	check_for_error(err)
-	w.Flush()
+	if err = binary.Write(w, binary.LittleEndian, s); err != nil {
+		return err
+	}
+	return w.Flush()
 }
 
 // ------------------------------------------------------------------
@@ -57,69 +121,148 @@ func _save_sequenceType(s []sequenceType, filename string) {
 // 	0 - do not save suffix array and seq
 //		1 - save suffix array, but not seq
 //		2 - save both suffix array and seq
+//		3 - save a sampled suffix array, but not the full one nor seq
+// A sampled suffix array (see SampleSuffixArray) is saved whenever
+// there is one.  Option 3 samples at DEFAULT_SA_RATE if needed, except
+// with the run-length backend, which locates from its own samples.
+// The index is saved to BuildOptions.OutputDir, or to the input file
+// name followed by ".fmi".
 // ------------------------------------------------------------------
 func (I *IndexC) SaveCompressedIndex(save_option int) {
-	dir := I.input_file + ".fmi"
-	os.Mkdir(dir, 0777)
+	dir := I.output_dir
+	if dir == "" {
+		if I.input_file == "" {
+			panic("SaveCompressedIndex: no output directory; set BuildOptions.OutputDir")
+		}
+		dir = I.input_file + ".fmi"
+	}
+	check_for_error(I.Save(dir, save_option))
+}
 
-	var wg sync.WaitGroup
-	wg.Add(len(I.SYMBOLS) + 4)
+//-----------------------------------------------------------------------------
+// Save the index to directory dir, which is created if needed.  See
+// SaveCompressedIndex for the meaning of save_option.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Save(dir string, save_option int) error {
+	if save_option < 0 || save_option > 3 {
+		return fmt.Errorf("Save: unknown save option %d", save_option)
+	}
+	if (save_option == 1 || save_option == 2) && I.SA == nil {
+		return fmt.Errorf("Save: suffix array is not loaded")
+	}
+	if save_option == 2 && I.SEQ == nil {
+		return fmt.Errorf("Save: seq is not loaded")
+	}
+	if save_option == 3 && I.SA_SAMPLE == nil && I.run_length() == nil {
+		if I.SA == nil {
+			return fmt.Errorf("Save: suffix array is not loaded")
+		}
+		I.SampleSuffixArray(DEFAULT_SA_RATE)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
 
-	go func() {
-		defer wg.Done()
-		err := ioutil.WriteFile(path.Join(dir, "bwt"), I.BWT, 0666)
-		check_for_error(err)
-	}()
+	g := errGroup{sem: make(chan struct{}, I.workers())}
+	g.Go(func() error {
+		return I.bwt.save(dir)
+	})
 
-	go func() {
-		defer wg.Done()
-		_save_sequenceType(I.SSA, path.Join(dir, "ssa"))
-	}()
+	g.Go(func() error {
+		if I.SSA != nil {
+			return _save_binary(I.SSA.raw(), path.Join(dir, "ssa"))
+		}
+		return nil
+	})
 
-	go func() {
-		defer wg.Done()
+	g.Go(func() error {
 		if save_option == 1 || save_option == 2 {
-			_save_indexType(I.SA, path.Join(dir, "sa"))
+			return _save_binary(I.SA.raw(), path.Join(dir, "sa"))
 		}
-	}()
+		return nil
+	})
 
-	go func() {
-		defer wg.Done()
+	g.Go(func() error {
 		if save_option == 2 {
-			err := ioutil.WriteFile(path.Join(dir, "seq"), I.SEQ, 0666)
-			check_for_error(err)
+			return ioutil.WriteFile(path.Join(dir, "seq"), I.SEQ, 0666)
 		}
-	}()
-
-	for symb := range I.OCC {
-		go func(symb byte) {
-			defer wg.Done()
-			_save_indexType(I.OCC[symb], path.Join(dir, "occ."+string(symb)))
-		}(symb)
-	}
+		return nil
+	})
+
+	g.Go(func() error {
+		if I.SA_SAMPLE != nil {
+			if err := _save_binary(I.SA_SAMPLE.raw(), path.Join(dir, "sa_sample")); err != nil {
+				return err
+			}
+			return _save_binary(I.SA_MARK.bits, path.Join(dir, "sa_mark"))
+		}
+		return nil
+	})
 
-	f, err := os.Create(path.Join(dir, "others"))
-	check_for_error(err)
-	defer f.Close()
-	w := bufio.NewWriter(f)
-	fmt.Fprintf(w, "%d %d %d %d %t %d\n", I.LEN, I.OCC_SIZE, I.END_POS, I.M, I.Multiple, save_option)
-	for i := 0; i < len(I.SYMBOLS); i++ {
-		symb := byte(I.SYMBOLS[i])
-		fmt.Fprintf(w, "%s %d %d %d\n", string(symb), I.Freq[symb], I.C[symb], I.EP[symb])
-	}
-	w.Flush()
+	g.Go(func() error {
+		f, err := os.Create(path.Join(dir, "others"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		sa_rate := 0
+		if I.SA_SAMPLE != nil {
+			sa_rate = I.SA_RATE
+		}
+		a := &I.Alphabet
+		sid_width := width_for(uint64(I.n_stored()))
+		if I.SSA != nil {
+			sid_width = I.SSA.Width()
+		}
+		header := []struct {
+			key   string
+			value interface{}
+		}{
+			{"len", I.LEN}, {"occ_size", I.OCC_SIZE}, {"end_pos", I.END_POS}, {"ratio", I.M},
+			{"multiple", I.Multiple}, {"save_option", save_option}, {"sa_rate", sa_rate},
+			{"separator", I.SEP}, {"terminator", I.TERM}, {"fold_case", a.FoldCase}, {"iupac", a.IUPAC},
+			{"n_break", a.NBreak}, {"break", a.Break}, {"reverse_complement", I.ReverseComplement},
+			{"sid_width", sid_width}, {"width", I.WIDTH}, {"backend", I.bwt.Name()}, {"runs", I.RUNS},
+			{"bidirectional", I.REV != nil},
+		}
+		for _, h := range header {
+			fmt.Fprintln(w, h.key, h.value)
+		}
+		for i := 0; i < len(I.SYMBOLS); i++ {
+			symb := byte(I.SYMBOLS[i])
+			fmt.Fprintf(w, "symbol %d %d %d %d\n", symb, I.Freq[symb], I.C[symb], I.EP[symb])
+		}
+		return w.Flush()
+	})
 
 	// save genome info
-	f, err = os.Create(path.Join(dir, "genome_lengths"))
-	check_for_error(err)
-	defer f.Close()
-	w = bufio.NewWriter(f)
-	for i := 0; i < len(I.GENOME_ID); i++ {
-		fmt.Fprintf(w, "%d %s\n", I.LENS[i], I.GENOME_ID[i])
-	}
-	w.Flush()
+	g.Go(func() error {
+		f, err := os.Create(path.Join(dir, "genome_lengths"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		for i := 0; i < len(I.GENOME_ID); i++ {
+			fmt.Fprintf(w, "%d %s\n", I.LENS[i], I.GENOME_ID[i])
+		}
+		return w.Flush()
+	})
+
+	g.Go(func() error {
+		return ioutil.WriteFile(path.Join(dir, "version"), []byte(fmt.Sprintf("%d\n", FORMAT_VERSION)), 0666)
+	})
+
+	// the index of the reversed text is a whole index of its own
+	g.Go(func() error {
+		if I.REV != nil {
+			return I.REV.Save(path.Join(dir, "reverse"), 0)
+		}
+		return nil
+	})
 
-	wg.Wait()
+	return g.Wait()
 }
 
 // ------------------------------------------------------------------
@@ -127,129 +270,288 @@ func (I *IndexC) SaveCompressedIndex(save_option int) {
 // 	0 - suffix array and seq were not saved
 //		1 - suffix array was saved; seq was not
 //		2 - both suffix array and seq were saved
+//		3 - sampled suffix array was saved; full suffix array and seq were not
 // ------------------------------------------------------------------
 func LoadCompressedIndex(dir string) *IndexC {
-	I := new(IndexC)
-
-	// First, load "others"
-	f, err := os.Open(path.Join(dir, "others"))
+	I, err := Load(dir)
 	check_for_error(err)
-	defer f.Close()
+	return I
+}
 
-	var symb byte
-	var freq, c, ep indexType
-	var save_option int
-	scanner := bufio.NewScanner(f)
-	scanner.Scan()
-	fmt.Sscanf(scanner.Text(), "%d%d%d%d%t%d\n", &I.LEN, &I.OCC_SIZE, &I.END_POS, &I.M, &I.Multiple, &save_option)
+//-----------------------------------------------------------------------------
+// Load an index saved by Save.  Missing, truncated or malformed files are
+// reported as *CorruptIndexError; indexes written by a newer version of
+// this package are reported as *VersionError.
+//-----------------------------------------------------------------------------
+func Load(dir string) (*IndexC, error) {
+	version, err := _load_version(dir)
+	if err != nil {
+		return nil, err
+	}
+	if version > FORMAT_VERSION {
+		return nil, &VersionError{Found: version, Supported: FORMAT_VERSION}
+	}
+	if version < 0 {
+		return nil, &CorruptIndexError{path.Join(dir, "version"), fmt.Errorf("negative version %d", version)}
+	}
 
-	I.Freq = make(map[byte]indexType)
-	I.C = make(map[byte]indexType)
-	I.EP = make(map[byte]indexType)
-	for scanner.Scan() {
-		fmt.Sscanf(scanner.Text(), "%c%d%d%d", &symb, &freq, &c, &ep)
-		I.SYMBOLS = append(I.SYMBOLS, int(symb))
-		I.Freq[symb], I.C[symb], I.EP[symb] = freq, c, ep
+	I := new(IndexC)
+
+	// First, load "others"
+	save_option, sid_width, bidirectional, err := I._load_others(path.Join(dir, "others"), version)
+	if err != nil {
+		return nil, err
 	}
 
 	// load genome_info
-	f, err = os.Open(path.Join(dir, "genome_lengths"))
-	check_for_error(err)
-	defer f.Close()
-	scanner = bufio.NewScanner(f)
-	var items []string
-	for scanner.Scan() {
-		items = strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
-		I.GENOME_ID = append(I.GENOME_ID, items[1])
-		cur_len, _ := strconv.Atoi(items[0])
-		I.LENS = append(I.LENS, indexType(cur_len))
+	if err = I._load_genome_lengths(path.Join(dir, "genome_lengths")); err != nil {
+		return nil, err
 	}
 
-	// Second, load Suffix array, BWT and OCC
-	I.OCC = make(map[byte][]indexType)
-	var wg sync.WaitGroup
-	wg.Add(len(I.SYMBOLS) + 4)
-
-	go func() {
-		defer wg.Done()
-		I.BWT, err = ioutil.ReadFile(path.Join(dir, "bwt"))
-		check_for_error(err)
-	}()
+	// Second, load Suffix array and BWT.  Version 0 saved the BWT and the
+	// occurence table separately; the rank backend is rebuilt from the BWT
+	// and the occurence table is ignored.
+	var g errGroup
+	var bwt []byte
+	if version > 0 {
+		if I.bwt = I.rank_layout(I.backend); I.bwt == nil {
+			return nil, &CorruptIndexError{path.Join(dir, "others"), fmt.Errorf("unknown rank backend %q", I.backend)}
+		}
+	}
+	g.Go(func() error {
+		var err error
+		if version == 0 {
+			bwt, err = _load_bytes(path.Join(dir, "bwt"), I.LEN)
+		} else {
+			err = I.bwt.load(dir)
+		}
+		return err
+	})
 
-	go func() {
-		defer wg.Done()
-		I.SSA = _load_sequenceType(path.Join(dir, "ssa"), I.LEN)
-	}()
+	g.Go(func() error {
+		if I.Multiple && I.backend != BACKEND_RLE {
+			I.SSA = newUintArray(sid_width, I.LEN)
+			return _load_binary(path.Join(dir, "ssa"), I.SSA.raw())
+		}
+		return nil
+	})
 
-	go func() {
-		defer wg.Done()
+	g.Go(func() error {
 		if save_option == 1 || save_option == 2 {
-			I.SA = _load_indexType(path.Join(dir, "sa"), I.LEN)
+			I.SA = newUintArray(I.WIDTH, I.LEN)
+			return _load_binary(path.Join(dir, "sa"), I.SA.raw())
 		}
-	}()
+		return nil
+	})
 
-	go func() {
-		defer wg.Done()
+	g.Go(func() error {
+		var err error
 		if save_option == 2 {
-			I.SEQ, err = ioutil.ReadFile(path.Join(dir, "seq"))
-			check_for_error(err)
+			I.SEQ, err = _load_bytes(path.Join(dir, "seq"), I.LEN)
 		}
-	}()
+		return err
+	})
+
+	g.Go(func() error {
+		if I.SA_RATE > 0 {
+			rate := indexType(I.SA_RATE)
+			I.SA_SAMPLE = newUintArray(I.WIDTH, (I.LEN+rate-1)/rate)
+			if err := _load_binary(path.Join(dir, "sa_sample"), I.SA_SAMPLE.raw()); err != nil {
+				return err
+			}
+			I.SA_MARK = newBitVector(I.LEN)
+			return _load_binary(path.Join(dir, "sa_mark"), I.SA_MARK.bits)
+		}
+		return nil
+	})
 
-	Symb_OCC_chan := make(chan Symb_OCC)
-	for _, symb := range I.SYMBOLS {
-		go func(symb int) {
-			defer wg.Done()
-			Symb_OCC_chan <- Symb_OCC{symb, _load_indexType(path.Join(dir, "occ."+string(symb)), I.OCC_SIZE)}
-		}(symb)
+	g.Go(func() error {
+		var err error
+		if bidirectional {
+			I.REV, err = Load(path.Join(dir, "reverse"))
+		}
+		return err
+	})
+
+	if err = g.Wait(); err != nil {
+		return nil, err
 	}
-	go func() {
-		wg.Wait()
-		close(Symb_OCC_chan)
-	}()
+	if bwt != nil {
+		I.bwt = I.new_rank(bwt, I.backend)
+	}
+	if I.RUNS < 0 {
+		I.RUNS = I.bwt_runs()
+	}
+	if I.SA_MARK != nil {
+		I.SA_MARK.build_rank()
+		I.sample_inverse()
+	}
+	I.compute_starts()
+	return I, nil
+}
 
-	for symb_occ := range Symb_OCC_chan {
-		I.OCC[byte(symb_occ.Symb)] = symb_occ.OCC
+//-----------------------------------------------------------------------------
+func _load_version(dir string) (int, error) {
+	b, err := ioutil.ReadFile(path.Join(dir, "version"))
+	if os.IsNotExist(err) {
+		if _, err = os.Stat(dir); err != nil {
+			return 0, err
+		}
+		return 0, nil
 	}
-	return I
+	if err != nil {
+		return 0, &CorruptIndexError{path.Join(dir, "version"), err}
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, &CorruptIndexError{path.Join(dir, "version"), err}
+	}
+	return version, nil
 }
 
 //-----------------------------------------------------------------------------
-func _load_indexType(filename string, length indexType) []indexType {
+// Reads the header and the count table; returns the save option, the
+// width of SSA entries and whether the reversed text is indexed.  Version 0
+// has a single positional header line, and everything added since takes
+// its default.  Later versions have a "key value" line per field, where
+// unknown keys are skipped and missing optional ones take their default,
+// and a "symbol" line per symbol.
+func (I *IndexC) _load_others(filename string, version int) (int, int, bool, error) {
 	f, err := os.Open(filename)
-	check_for_error(err)
+	if err != nil {
+		return 0, 0, false, &CorruptIndexError{filename, err}
+	}
 	defer f.Close()
-	numBytes := uint(unsafe.Sizeof(indexType(0)))
-	v := make([]indexType, length)
+
+	var symb byte
+	var freq, c, ep indexType
+	var save_option int
+	var bidirectional bool
+	I.SEP, I.TERM = '|', '$'
+	a := &I.Alphabet
+	sid_width := 2
+	I.WIDTH = 8
+	I.RUNS = -1
+	I.Freq = make(map[byte]indexType)
+	I.C = make(map[byte]indexType)
+	I.EP = make(map[byte]indexType)
+	add_symbol := func(line, format string) error {
+		if n, err := fmt.Sscanf(line, format, &symb, &freq, &c, &ep); n != 4 {
+			return &CorruptIndexError{filename, fmt.Errorf("malformed symbol line %q: %v", line, err)}
+		}
+		I.SYMBOLS = append(I.SYMBOLS, int(symb))
+		I.Freq[symb], I.C[symb], I.EP[symb] = freq, c, ep
+		return nil
+	}
 
 	scanner := bufio.NewScanner(f)
-	scanner.Split(bufio.ScanBytes)
-	for i, b := 0, uint(0); scanner.Scan(); b++ {
-		if b == numBytes {
-			b, i = 0, i+1
+	if version == 0 {
+		if !scanner.Scan() {
+			return 0, 0, false, &CorruptIndexError{filename, io.ErrUnexpectedEOF}
+		}
+		if n, _ := fmt.Sscanf(scanner.Text(), "%d%d%d%d%t%d\n", &I.LEN, &I.OCC_SIZE, &I.END_POS, &I.M, &I.Multiple, &save_option); n != 6 {
+			return 0, 0, false, &CorruptIndexError{filename, fmt.Errorf("malformed header %q", scanner.Text())}
+		}
+		for scanner.Scan() {
+			if err = add_symbol(scanner.Text(), "%c%d%d%d"); err != nil {
+				return 0, 0, false, err
+			}
+		}
+	} else {
+		fields := map[string]interface{}{
+			"len": &I.LEN, "occ_size": &I.OCC_SIZE, "end_pos": &I.END_POS, "ratio": &I.M,
+			"multiple": &I.Multiple, "save_option": &save_option, "sa_rate": &I.SA_RATE,
+			"separator": &I.SEP, "terminator": &I.TERM, "fold_case": &a.FoldCase, "iupac": &a.IUPAC,
+			"n_break": &a.NBreak, "break": &a.Break, "reverse_complement": &I.ReverseComplement,
+			"sid_width": &sid_width, "width": &I.WIDTH, "backend": &I.backend, "runs": &I.RUNS,
+			"bidirectional": &bidirectional,
+		}
+		seen := make(map[string]bool)
+		for scanner.Scan() {
+			items := append(strings.SplitN(scanner.Text(), " ", 2), "")
+			if key := items[0]; key == "symbol" {
+				err = add_symbol(items[1], "%d%d%d%d")
+			} else if v, ok := fields[key]; ok {
+				if _, err = fmt.Sscan(items[1], v); err != nil {
+					err = &CorruptIndexError{filename, fmt.Errorf("malformed field %q: %v", scanner.Text(), err)}
+				}
+				seen[key] = true
+			}
+			if err != nil {
+				return 0, 0, false, err
+			}
 		}
-		v[i] += indexType(scanner.Bytes()[0]) << (b * 8)
+		for _, key := range []string{"len", "occ_size", "end_pos", "ratio", "save_option", "width", "backend"} {
+			if !seen[key] {
+				return 0, 0, false, &CorruptIndexError{filename, fmt.Errorf("missing field %s", key)}
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, 0, false, &CorruptIndexError{filename, err}
+	}
+	if I.LEN < 0 || I.OCC_SIZE < 0 || I.M < 1 || save_option < 0 || save_option > 3 || I.SA_RATE < 0 ||
+		(sid_width != 2 && sid_width != 4 && sid_width != 8) || (I.WIDTH != 4 && I.WIDTH != 8) ||
+		(I.backend == BACKEND_RLE && I.RUNS < 1) {
+		return 0, 0, false, &CorruptIndexError{filename, fmt.Errorf("inconsistent header")}
 	}
-	return v
+	return save_option, sid_width, bidirectional, nil
 }
 
 //-----------------------------------------------------------------------------
-func _load_sequenceType(filename string, length indexType) []sequenceType {
+func (I *IndexC) _load_genome_lengths(filename string) error {
 	f, err := os.Open(filename)
-	check_for_error(err)
+	if err != nil {
+		return &CorruptIndexError{filename, err}
+	}
 	defer f.Close()
-	numBytes := uint(unsafe.Sizeof(sequenceType(0)))
-	v := make([]sequenceType, length)
-
 	scanner := bufio.NewScanner(f)
-	scanner.Split(bufio.ScanBytes)
-	for i, b := 0, uint(0); scanner.Scan(); b++ {
-		if b == numBytes {
-			b, i = 0, i+1
+	var items []string
+	for scanner.Scan() {
+		items = strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 2)
+		cur_len, err := strconv.Atoi(items[0])
+		if err != nil {
+			return &CorruptIndexError{filename, err}
+		}
+		if len(items) < 2 {
+			items = append(items, "")
 		}
-		v[i] += sequenceType(scanner.Bytes()[0]) << (b * 8)
+		I.GENOME_ID = append(I.GENOME_ID, items[1])
+		I.LENS = append(I.LENS, indexType(cur_len))
+	}
+	if err = scanner.Err(); err != nil {
+		return &CorruptIndexError{filename, err}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Fills v, a slice of fixed-size values, from a file written by _save_binary.
+func _load_binary(filename string, v interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return &CorruptIndexError{filename, err}
+	}
+	defer f.Close()
+	if err = binary.Read(bufio.NewReader(f), binary.LittleEndian, v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return &CorruptIndexError{filename, err}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+func _load_bytes(filename string, length indexType) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, &CorruptIndexError{filename, err}
+	}
+	if indexType(len(b)) != length {
+		return nil, &CorruptIndexError{filename, fmt.Errorf("expected %d bytes, found %d", length, len(b))}
 	}
-	return v
+	return b, nil
 }
 
 //-----------------------------------------------------------------------------
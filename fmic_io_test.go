@@ -0,0 +1,176 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Writes I to dir as the original package did, before the format was
+// versioned: a positional header, the BWT, 16-bit SSA and 64-bit SA.
+func save_version0(t *testing.T, I *IndexC, dir string) {
+	var others bytes.Buffer
+	fmt.Fprintf(&others, "%d %d %d %d %t %d\n", I.LEN, I.OCC_SIZE, I.END_POS, I.M, I.Multiple, 1)
+	for _, c := range I.SYMBOLS {
+		fmt.Fprintf(&others, "%s %d %d %d\n", string(rune(c)), I.Freq[byte(c)], I.C[byte(c)], I.EP[byte(c)])
+	}
+	var lengths bytes.Buffer
+	for s := range I.GENOME_ID {
+		fmt.Fprintf(&lengths, "%d %s\n", I.LENS[s], I.GENOME_ID[s])
+	}
+	ssa, sa := make([]uint16, I.LEN), make([]int64, I.LEN)
+	for i := range sa {
+		ssa[i], sa[i] = uint16(I.SSA.Get(i)), int64(I.SA.Get(i))
+	}
+	var ssa_bytes, sa_bytes bytes.Buffer
+	binary.Write(&ssa_bytes, binary.LittleEndian, ssa)
+	binary.Write(&sa_bytes, binary.LittleEndian, sa)
+	for name, b := range map[string][]byte{
+		"others": others.Bytes(), "genome_lengths": lengths.Bytes(), "bwt": naive_bwt(I),
+		"ssa": ssa_bytes.Bytes(), "sa": sa_bytes.Bytes(),
+	} {
+		if err := ioutil.WriteFile(path.Join(dir, name), b, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Indexes saved before versioning, and indexes saved now, load and answer
+// queries as the index they were saved from.
+func TestLoadVersions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 3, 1000)
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := t.TempDir()
+	save_version0(t, I, old)
+	current := t.TempDir()
+	if err := I.Save(current, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{old, current} {
+		J, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(J.GENOME_ID, I.GENOME_ID) || !reflect.DeepEqual(J.LENS, I.LENS) {
+			t.Fatalf("%s: sequences %v %v, want %v %v", dir, J.GENOME_ID, J.LENS, I.GENOME_ID, I.LENS)
+		}
+		check_rank(t, J, naive_bwt(I))
+		for n := 0; n < 50; n++ {
+			_, _, q := random_substring(rng, records, 5+rng.Intn(30))
+			s1, c1 := I.Guess(q, 0)
+			s2, c2 := J.Guess(q, 0)
+			if h1, h2 := I.Locate(q, 0), J.Locate(q, 0); !reflect.DeepEqual(h1, h2) || s1 != s2 || c1 != c2 {
+				t.Fatalf("%s: %s located at %v and guessed in %d, want %v and %d", dir, q, h2, s2, h1, s1)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// The header of "others" is read by key: unknown keys are skipped, and a
+// missing field is an error.
+func TestLoadKeyedHeader(t *testing.T) {
+	I, err := BuildFromBytes([]byte("ACGTTGCAACGT"), BuildOptions{Ratio: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := I.Save(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	others, err := ioutil.ReadFile(path.Join(dir, "others"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path.Join(dir, "others"), append([]byte("future_field 7\n"), others...), 0666)
+	J, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := J.Search([]byte("GCAA")); r.Sp != r.Ep {
+		t.Fatalf("GCAA found at rows %v after an unknown key", r)
+	}
+	ioutil.WriteFile(path.Join(dir, "others"), bytes.Replace(others, []byte("ratio 2\n"), nil, 1), 0666)
+	if _, err := Load(dir); err == nil {
+		t.Fatal("header without ratio loaded")
+	}
+	os.Remove(path.Join(dir, "version"))
+	ioutil.WriteFile(path.Join(dir, "others"), others, 0666)
+	if _, err := Load(dir); err == nil {
+		t.Fatal("keyed header loaded as version 0")
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Load reports a missing directory, missing, truncated and malformed files
+// and a newer format with errors of the documented types.
+func TestLoadErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	I, err := BuildFromRecords(random_strains(rng, 3, 500), BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path.Join(t.TempDir(), "none")); !os.IsNotExist(err) {
+		t.Fatalf("missing directory: %v", err)
+	}
+	truncate := func(dir, name string) {
+		b, _ := ioutil.ReadFile(path.Join(dir, name))
+		ioutil.WriteFile(path.Join(dir, name), b[:len(b)/2], 0666)
+	}
+	tests := []struct {
+		name    string
+		corrupt func(dir string)
+		file    string
+	}{
+		{"missing others", func(dir string) { os.Remove(path.Join(dir, "others")) }, "others"},
+		{"missing lengths", func(dir string) { os.Remove(path.Join(dir, "genome_lengths")) }, "genome_lengths"},
+		{"missing SA", func(dir string) { os.Remove(path.Join(dir, "sa")) }, "sa"},
+		{"truncated SA", func(dir string) { truncate(dir, "sa") }, "sa"},
+		{"truncated SSA", func(dir string) { truncate(dir, "ssa") }, "ssa"},
+		{"truncated BWT", func(dir string) { truncate(dir, "rank") }, "rank"},
+		{"truncated seq", func(dir string) { truncate(dir, "seq") }, "seq"},
+		{"empty others", func(dir string) { ioutil.WriteFile(path.Join(dir, "others"), nil, 0666) }, "others"},
+		{"malformed version", func(dir string) { ioutil.WriteFile(path.Join(dir, "version"), []byte("x\n"), 0666) }, "version"},
+		{"malformed lengths", func(dir string) { ioutil.WriteFile(path.Join(dir, "genome_lengths"), []byte("x y\n"), 0666) }, "genome_lengths"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		if err := I.Save(dir, 2); err != nil {
+			t.Fatal(err)
+		}
+		test.corrupt(dir)
+		_, err := Load(dir)
+		var corrupt *CorruptIndexError
+		if !errors.As(err, &corrupt) || corrupt.File != path.Join(dir, test.file) {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := I.Save(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path.Join(dir, "version"), []byte(fmt.Sprintf("%d\n", FORMAT_VERSION+1)), 0666)
+	_, err = Load(dir)
+	var version *VersionError
+	if !errors.As(err, &version) || version.Found != FORMAT_VERSION+1 || version.Supported != FORMAT_VERSION {
+		t.Fatalf("newer version: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Random bases from alphabet.
+func random_seq(rng *rand.Rand, n int, alphabet string) []byte {
+	seq := make([]byte, n)
+	for i := range seq {
+		seq[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return seq
+}
+
+//-----------------------------------------------------------------------------
+// n variants of a random DNA sequence of length L, each with a few
+// substitutions, so that the collection is repetitive.
+func random_strains(rng *rand.Rand, n, L int) []Record {
+	base := random_seq(rng, L, "ACGT")
+	var records []Record
+	for s := 0; s < n; s++ {
+		seq := append([]byte(nil), base...)
+		for k := 0; k < 5; k++ {
+			seq[rng.Intn(L)] = "ACGTN"[rng.Intn(5)]
+		}
+		records = append(records, Record{fmt.Sprint("strain", s), seq})
+	}
+	return records
+}
+
+//-----------------------------------------------------------------------------
+// A substring of a random record: its sequence number, offset and bases.
+func random_substring(rng *rand.Rand, records []Record, m int) (int, int, []byte) {
+	s := rng.Intn(len(records))
+	p := rng.Intn(len(records[s].Seq) - m + 1)
+	return s, p, append([]byte(nil), records[s].Seq[p:p+m]...)
+}
+
+//-----------------------------------------------------------------------------
+// The bases of the text at hit h, m long.
+func hit_text(records []Record, h Hit, m int) []byte {
+	return records[h.SeqID].Seq[h.Offset : h.Offset+m]
+}
+
+//-----------------------------------------------------------------------------
+// The BWT of I, read off its text and suffix array.
+func naive_bwt(I *IndexC) []byte {
+	bwt := make([]byte, I.LEN)
+	for i := range bwt {
+		p := int(I.SA.Get(i))
+		if p == 0 {
+			p = int(I.LEN)
+		}
+		bwt[i] = I.SEQ[p-1]
+	}
+	return bwt
+}
+
+//-----------------------------------------------------------------------------
+// Checks Access and Rank of the backend of I against bwt, at every row and
+// for every symbol.
+func check_rank(t *testing.T, I *IndexC, bwt []byte) {
+	b := I.Backend()
+	if b.Sigma() != len(I.SYMBOLS) {
+		t.Fatalf("%s: sigma %d, want %d", b.Name(), b.Sigma(), len(I.SYMBOLS))
+	}
+	count := make(map[byte]int)
+	for i := 0; i <= len(bwt); i++ {
+		for _, c := range I.SYMBOLS {
+			if r := b.Rank(byte(c), i); r != count[byte(c)] {
+				t.Fatalf("%s: rank of %q at %d is %d, want %d", b.Name(), c, i, r, count[byte(c)])
+			}
+		}
+		if i < len(bwt) {
+			if c := b.Access(i); c != bwt[i] {
+				t.Fatalf("%s: BWT[%d] is %q, want %q", b.Name(), i, c, bwt[i])
+			}
+			count[bwt[i]]++
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Checks that Locate finds every occurrence, and only occurrences, of random
+// substrings of records.
+func check_locate(t *testing.T, rng *rand.Rand, I *IndexC, records []Record) {
+	for n := 0; n < 100; n++ {
+		_, _, q := random_substring(rng, records, 1+rng.Intn(12))
+		want := 0
+		for _, r := range records {
+			for p := 0; p+len(q) <= len(r.Seq); p++ {
+				if bytes.Equal(r.Seq[p:p+len(q)], q) {
+					want++
+				}
+			}
+		}
+		hits := I.Locate(q, 0)
+		if len(hits) != want {
+			t.Fatalf("%s: %s located %d times, want %d", I.Backend().Name(), q, len(hits), want)
+		}
+		for _, h := range hits {
+			if !bytes.Equal(hit_text(records, h, len(q)), q) {
+				t.Fatalf("%s: %s located at %v", I.Backend().Name(), q, h)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Builds records with backend, checks its rank queries and Locate, and checks
+// them again after saving the index with each save option and loading it.
+func check_backend(t *testing.T, rng *rand.Rand, backend string, records []Record) {
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true, Backend: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if I.Backend().Name() != backend {
+		t.Fatalf("built %s, want %s", I.Backend().Name(), backend)
+	}
+	bwt := naive_bwt(I)
+	check_rank(t, I, bwt)
+	check_locate(t, rng, I, records)
+	for option := 0; option <= 3; option++ {
+		dir := t.TempDir()
+		if err := I.Save(dir, option); err != nil {
+			t.Fatal(err)
+		}
+		J, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check_rank(t, J, bwt)
+		check_locate(t, rng, J, records)
+	}
+}
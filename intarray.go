@@ -0,0 +1,78 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+//-----------------------------------------------------------------------------
+// UintArray is an array of unsigned integers stored in 2, 4 or 8 bytes each.
+// The width is chosen when the array is created.
+//-----------------------------------------------------------------------------
+type UintArray interface {
+	Get(i int) uint64
+	Set(i int, v uint64)
+	Len() int
+	Width() int // bytes per entry
+	raw() interface{}
+}
+
+type uint16Array []uint16
+type uint32Array []uint32
+type uint64Array []uint64
+
+func (a uint16Array) Get(i int) uint64    { return uint64(a[i]) }
+func (a uint16Array) Set(i int, v uint64) { a[i] = uint16(v) }
+func (a uint16Array) Len() int            { return len(a) }
+func (a uint16Array) Width() int          { return 2 }
+func (a uint16Array) raw() interface{}    { return []uint16(a) }
+
+func (a uint32Array) Get(i int) uint64    { return uint64(a[i]) }
+func (a uint32Array) Set(i int, v uint64) { a[i] = uint32(v) }
+func (a uint32Array) Len() int            { return len(a) }
+func (a uint32Array) Width() int          { return 4 }
+func (a uint32Array) raw() interface{}    { return []uint32(a) }
+
+func (a uint64Array) Get(i int) uint64    { return a[i] }
+func (a uint64Array) Set(i int, v uint64) { a[i] = v }
+func (a uint64Array) Len() int            { return len(a) }
+func (a uint64Array) Width() int          { return 8 }
+func (a uint64Array) raw() interface{}    { return []uint64(a) }
+
+//-----------------------------------------------------------------------------
+// Returns an array of n zeros, width bytes each.
+func newUintArray(width int, n indexType) UintArray {
+	switch width {
+	case 2:
+		return make(uint16Array, n)
+	case 4:
+		return make(uint32Array, n)
+	}
+	return make(uint64Array, n)
+}
+
+//-----------------------------------------------------------------------------
+// a[i] and a[i] = v for arrays of text positions, rows and counts.
+func at(a UintArray, i indexType) indexType     { return indexType(a.Get(int(i))) }
+func put(a UintArray, i indexType, v indexType) { a.Set(int(i), uint64(v)) }
+
+//-----------------------------------------------------------------------------
+// Smallest width, in bytes, that holds the numbers 0 to n-1.
+func width_for(n uint64) int {
+	switch {
+	case n <= 1<<16:
+		return 2
+	case n <= 1<<32:
+		return 4
+	}
+	return 8
+}
+
+//-----------------------------------------------------------------------------
+// Width of the suffix array, its samples and the occurence table of a text
+// of length n: 4 bytes if positions and counts fit, 8 otherwise.
+func index_width(n indexType) int {
+	if width_for(uint64(n)+1) <= 4 {
+		return 4
+	}
+	return 8
+}
@@ -0,0 +1,126 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Widths change exactly where the largest value stops fitting, and arrays of
+// each width keep their largest value.
+func TestWidths(t *testing.T) {
+	tests := []struct {
+		n     uint64
+		width int
+	}{
+		{0, 2}, {1, 2}, {1 << 16, 2}, {1<<16 + 1, 4}, {1 << 32, 4}, {1<<32 + 1, 8}, {1<<64 - 1, 8},
+	}
+	for _, test := range tests {
+		if w := width_for(test.n); w != test.width {
+			t.Fatalf("width for %d values is %d, want %d", test.n, w, test.width)
+		}
+	}
+	if w := index_width(1<<16 + 1); w != 4 {
+		t.Fatalf("index width of a short text is %d, want 4", w)
+	}
+	if w := index_width(1<<32 - 1); w != 4 {
+		t.Fatalf("index width of a text of 2^32-1 symbols is %d, want 4", w)
+	}
+	if w := index_width(1 << 32); w != 8 {
+		t.Fatalf("index width of a text of 2^32 symbols is %d, want 8", w)
+	}
+	for _, width := range []int{2, 4, 8} {
+		a := newUintArray(width, 3)
+		max := uint64(1)<<(8*uint(width)) - 1
+		a.Set(1, max)
+		if a.Width() != width || a.Len() != 3 || a.Get(0) != 0 || a.Get(1) != max {
+			t.Fatalf("array of width %d: width %d, length %d, holds %d and %d", width, a.Width(), a.Len(), a.Get(0), a.Get(1))
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// With more than 65,536 sequences, counting reverse strands, sequence ids
+// are stored in 4 bytes, so Locate and SequenceIDs tell the sequences above
+// 65,535 apart, before and after saving.
+func TestManySequences(t *testing.T) {
+	// sequence k spells k in base 4, so that it occurs nowhere else
+	name := func(k int) []byte {
+		seq := make([]byte, 9)
+		for i := range seq {
+			seq[i] = "ACGT"[k%4]
+			k /= 4
+		}
+		return seq
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n    int
+		opts BuildOptions
+	}{
+		{1<<16 + 100, BuildOptions{Ratio: 8, Multiple: true}},
+		{1<<15 + 100, BuildOptions{Ratio: 8, Multiple: true, ReverseComplement: true}},
+	} {
+		records := make([]Record, test.n)
+		for k := range records {
+			records[k] = Record{fmt.Sprint("r", k), name(k)}
+		}
+		I, err := BuildFromRecords(records, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		if err := I.Save(dir, 0); err != nil {
+			t.Fatal(err)
+		}
+		saved, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for which, X := range map[string]*IndexC{"built": I, "saved": saved} {
+			if X.SSA.Width() != 4 {
+				t.Fatalf("%s: %d sequences stored in %d bytes", which, test.n, X.SSA.Width())
+			}
+			ids := []int{0, 1<<15 - 1, 1 << 15, test.n - 1}
+			for n := 0; n < 20; n++ {
+				ids = append(ids, rng.Intn(test.n))
+			}
+			for _, k := range ids {
+				q := name(k)
+				hits := X.Locate(q, 0)
+				if test.opts.ReverseComplement {
+					if rc := ReverseComplement(q); bytes_index(records, rc) >= 0 {
+						continue // also the reverse complement of a sequence
+					}
+				}
+				if want := []Hit{{k, 0, false}}; !reflect.DeepEqual(hits, want) {
+					t.Fatalf("%s: sequence %d located at %v, want %v", which, k, hits, want)
+				}
+				c := X.NewCursor()
+				for i := len(q) - 1; i >= 0; i-- {
+					c = c.Extend(q[i])
+				}
+				if seqs := c.SequenceIDs(); !reflect.DeepEqual(seqs, []int{k}) {
+					t.Fatalf("%s: sequence %d found in %v", which, k, seqs)
+				}
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Number of the record whose sequence is seq, or -1.
+func bytes_index(records []Record, seq []byte) int {
+	for k, r := range records {
+		if string(r.Seq) == string(seq) {
+			return k
+		}
+	}
+	return -1
+}
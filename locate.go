@@ -0,0 +1,192 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"sort"
+)
+
+// Hit is an occurrence of a query: SeqID indexes GENOME_ID and Offset is the
+// 0-based position of the occurrence inside that sequence.  If Reverse is
+// set, the reverse complement of the query occurs at Offset on the forward
+// strand.
+type Hit struct {
+	SeqID   int
+	Offset  int
+	Reverse bool // occurrence on the reverse strand
+}
+
+//-----------------------------------------------------------------------------
+// Returns the occurrences of query as (sequence, offset) pairs, in suffix
+// array order.  At most limit hits are returned, those of the first rows of
+// the range; limit <= 0 means no limit.  Works with or without the suffix
+// array loaded.
+// Indexes built with ReverseComplement report hits on both strands.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Locate(query []byte, limit int) []Hit {
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return []Hit{}
+	}
+	cur := I.search(query)
+	n := indexType(cur.Size())
+	if limit > 0 && indexType(limit) < n {
+		n = indexType(limit)
+	}
+	return cur.hits(n)
+}
+
+//-----------------------------------------------------------------------------
+// Hits of a pattern of length m at the first n rows of its range [sp, ep],
+// in suffix array order.  Without the suffix array, the run-length backend
+// walks φ up from the toehold at ep (see each_hit), one step per row above
+// sp+n-1 as well as per hit.
+func (I *IndexC) row_hits(sp, ep, t, n indexType, m int) []Hit {
+	hits := make([]Hit, 0, n)
+	if rle := I.run_length(); rle != nil && I.SA == nil {
+		if n == 0 {
+			return hits
+		}
+		for i := ep; i >= sp+n; i-- {
+			t = rle.phi(t)
+		}
+		I.each_hit(sp, sp+n-1, t, m, func(h Hit) bool {
+			hits = append(hits, h)
+			return true
+		})
+		for l, r := 0, len(hits)-1; l < r; l, r = l+1, r-1 {
+			hits[l], hits[r] = hits[r], hits[l]
+		}
+		return hits
+	}
+	for i := sp; i < sp+n; i++ {
+		hits = append(hits, I.forward_hit(I.locate_row(i), m))
+	}
+	return hits
+}
+
+//-----------------------------------------------------------------------------
+// Calls f with the hits at rows ep, ep-1, ..., sp of the range of a pattern
+// of length m, until f returns false.  t is the toehold SA[ep] (see
+// Cursor): without the suffix array, the run-length backend finds each row
+// with one step of φ from the row below, so it takes as many steps as rows
+// visited.
+func (I *IndexC) each_hit(sp, ep, t indexType, m int, f func(Hit) bool) {
+	rle := I.run_length()
+	if rle == nil || I.SA != nil {
+		for i := ep; i >= sp; i-- {
+			if !f(I.forward_hit(I.locate_row(i), m)) {
+				return
+			}
+		}
+		return
+	}
+	for i := ep; i >= sp; i-- {
+		if !f(I.forward_hit(I.text_to_hit(t), m)) {
+			return
+		}
+		if i > sp {
+			t = rle.phi(t)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Keeps SA[i] only for rows i whose suffix starts at a multiple of rate.
+// Missing entries are recovered with at most rate-1 LF steps.  The inverse
+// sample, used by Extract, is kept at the same rate.  The full
+// suffix array must be in memory; it can be dropped afterwards (I.SA = nil).
+//-----------------------------------------------------------------------------
+func (I *IndexC) SampleSuffixArray(rate int) {
+	if rate < 1 {
+		panic("SampleSuffixArray: rate must be at least 1")
+	}
+	if I.SA == nil {
+		panic("SampleSuffixArray: suffix array is not loaded")
+	}
+	I.SA_RATE = rate
+	I.SA_MARK = newBitVector(I.LEN)
+	I.SA_SAMPLE = newUintArray(I.WIDTH, (I.LEN+indexType(rate)-1)/indexType(rate))
+	k := indexType(0)
+	for i := indexType(0); i < I.LEN; i++ {
+		if p := at(I.SA, i); p%indexType(rate) == 0 {
+			I.SA_MARK.set(i)
+			put(I.SA_SAMPLE, k, p)
+			k++
+		}
+	}
+	I.SA_MARK.build_rank()
+	I.sample_inverse()
+}
+
+//-----------------------------------------------------------------------------
+// Sequence and offset of the suffix at row i of the suffix array.  Reverse
+// strands keep their internal sequence numbers; see forward_hit.
+func (I *IndexC) locate_row(i indexType) Hit {
+	if I.SA == nil && I.SA_SAMPLE == nil && I.SSA != nil {
+		// Walk back to the start of the sequence, which is preceded by a
+		// separator or is at the very beginning of the text.
+		j, steps := i, 0
+		for c := I.bwt.access(j); c != I.SEP && c != I.TERM; c = I.bwt.access(j) {
+			j = I.lf(j)
+			steps++
+		}
+		return Hit{SeqID: int(I.SSA.Get(int(i))), Offset: steps}
+	}
+	return I.text_to_hit(I.sa_value(i))
+}
+
+//-----------------------------------------------------------------------------
+// SA[i], computed by LF-stepping back to a sampled row, or to the start of
+// the text if neither the suffix array nor a sample of it is loaded.
+func (I *IndexC) sa_value(i indexType) indexType {
+	if I.SA != nil {
+		return at(I.SA, i)
+	}
+	var steps indexType
+	if I.SA_SAMPLE != nil {
+		for !I.SA_MARK.get(i) {
+			i = I.lf(i)
+			steps++
+		}
+		return at(I.SA_SAMPLE, I.SA_MARK.rank1(i)) + steps
+	}
+	for i != I.END_POS {
+		i = I.lf(i)
+		steps++
+	}
+	return steps
+}
+
+//-----------------------------------------------------------------------------
+// LF mapping: the row of the suffix that starts one position before SA[i].
+func (I *IndexC) lf(i indexType) indexType {
+	c := I.bwt.access(i)
+	return I.C[c] + I.bwt.rank(c, i)
+}
+
+//-----------------------------------------------------------------------------
+// Converts a position in the concatenated text to a (sequence, offset) pair.
+func (I *IndexC) text_to_hit(pos indexType) Hit {
+	starts := I.starts
+	s := sort.Search(len(starts), func(k int) bool { return starts[k] > pos }) - 1
+	if s < 0 {
+		s = 0
+	}
+	return Hit{SeqID: s, Offset: int(pos - starts[s])}
+}
+
+//-----------------------------------------------------------------------------
+// Computes the starting position of each sequence in the concatenated text.
+// Sequences are separated by a single separator, and reverse strands follow
+// the forward ones.
+func (I *IndexC) compute_starts() {
+	I.starts = make([]indexType, I.n_stored())
+	var p indexType
+	for s := range I.starts {
+		I.starts[s] = p
+		p += I.LENS[s%len(I.LENS)] + 1
+	}
+}
@@ -0,0 +1,164 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+)
+
+//-----------------------------------------------------------------------------
+// Returns the matching statistics of query: for each position i, the
+// cursor of the longest substring starting at i that occurs in the text.
+// Its Len is the matching statistic and its Range the rows of that
+// substring; Len is 0 if query[i] does not occur.  The query is normalized
+// by I.Alphabet.
+//-----------------------------------------------------------------------------
+func (I *IndexC) MatchingStatistics(query []byte) ([]Cursor, error) {
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return nil, err
+	}
+	return I.matching_statistics(query, nil), nil
+}
+
+//-----------------------------------------------------------------------------
+// Like MatchingStatistics, but counts only substrings that occur in
+// sequence seqID, on either strand with ReverseComplement.  The cursors
+// still hold the rows of every occurrence.  Sequences are looked up in SSA,
+// which must be kept: the rows of seqID are marked once, and kept until
+// another sequence is asked for, so that checking a substring takes two
+// ranks.
+//-----------------------------------------------------------------------------
+func (I *IndexC) MatchingStatisticsIn(query []byte, seqID int) ([]Cursor, error) {
+	if I.SSA == nil {
+		return nil, fmt.Errorf("MatchingStatisticsIn: index has no SSA")
+	}
+	if seqID < 0 || seqID >= len(I.LENS) {
+		return nil, fmt.Errorf("MatchingStatisticsIn: sequence id %d out of range", seqID)
+	}
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return nil, err
+	}
+	rows := I.rows_of(seqID)
+	return I.matching_statistics(query, func(c Cursor) bool {
+		return rows.rank1(c.ep+1) > rows.rank1(c.sp)
+	}), nil
+}
+
+//-----------------------------------------------------------------------------
+// The longest match starting at i ends where the last SMEM starting at or
+// before i ends, if that is after i.  Positions sharing that SMEM are
+// consecutive, so one backward search from its end covers them all, and
+// the backward searches take as many steps as the SMEMs are long.
+func (I *IndexC) matching_statistics(query []byte, ok func(Cursor) bool) []Cursor {
+	var spans [][2]int
+	if ok == nil && I.REV != nil {
+		spans = I.bi_smems(query)
+	} else {
+		spans = I.match_spans(query, ok)
+	}
+	end := make([]int, len(query))
+	k := -1
+	for i := range query {
+		for k+1 < len(spans) && spans[k+1][0] <= i {
+			k++
+		}
+		end[i] = i - 1
+		if k >= 0 && spans[k][1] > i {
+			end[i] = spans[k][1] - 1
+		}
+	}
+	ms := make([]Cursor, len(query))
+	for i := len(query) - 1; i >= 0; {
+		e := end[i]
+		if e < i {
+			ms[i] = I.NewCursor()
+			i--
+			continue
+		}
+		c := I.NewCursor()
+		for j := e; j > i; j-- {
+			c = c.extend(query[j])
+		}
+		for ; i >= 0 && end[i] == e; i-- {
+			c = c.extend(query[i])
+			ms[i] = c
+		}
+	}
+	return ms
+}
+
+//-----------------------------------------------------------------------------
+// SMEM spans [start, end) of query, by start, found by backward search.  If
+// ok is not nil, a substring occurs only if ok accepts its cursor.  Going
+// from right to left, the longest match ending at e, query[s..e], is an
+// SMEM; the next one ends at the last e' from which query[s-1..e'] occurs.
+// As a substring of an occurring string occurs too, e' is found by binary
+// search, so an SMEM of length L takes O(L log L) steps.
+func (I *IndexC) match_spans(query []byte, ok func(Cursor) bool) [][2]int {
+	occurs := func(a, b int) bool {
+		c := I.NewCursor()
+		for j := b; j >= a && !c.Empty(); j-- {
+			c = c.extend(query[j])
+		}
+		return !c.Empty() && (ok == nil || ok(c))
+	}
+	var spans [][2]int
+	for e := len(query) - 1; e >= 0; {
+		c := I.NewCursor()
+		s := e
+		for ; s >= 0; s-- {
+			if c = c.extend(query[s]); c.Empty() || ok != nil && !ok(c) {
+				break
+			}
+		}
+		s++
+		if s > e {
+			e--
+			continue
+		}
+		spans = append(spans, [2]int{s, e + 1})
+		if s == 0 {
+			break
+		}
+		if !occurs(s-1, s-1) {
+			e = s - 2
+			continue
+		}
+		lo, hi := s-1, e-1
+		for lo < hi {
+			if mid := (lo + hi + 1) / 2; occurs(s-1, mid) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		e = lo
+	}
+	for l, r := 0, len(spans)-1; l < r; l, r = l+1, r-1 {
+		spans[l], spans[r] = spans[r], spans[l]
+	}
+	return spans
+}
+
+//-----------------------------------------------------------------------------
+// Rows whose suffix lies in sequence seqID, on either strand, with rank.
+// The rows of the last sequence asked for are kept.
+func (I *IndexC) rows_of(seqID int) *bitVector {
+	I.seq_rows_mu.Lock()
+	defer I.seq_rows_mu.Unlock()
+	if I.seq_rows == nil || I.seq_rows_id != seqID {
+		rows := newBitVector(I.LEN)
+		for i := 0; i < int(I.LEN); i++ {
+			if I.forward_id(int(I.SSA.Get(i))) == seqID {
+				rows.set(indexType(i))
+			}
+		}
+		rows.build_rank()
+		I.seq_rows, I.seq_rows_id = rows, seqID
+	}
+	return I.seq_rows
+}
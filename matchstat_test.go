@@ -0,0 +1,62 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Length of the longest prefix of q that occurs in text.
+func brute_match(text, q []byte) int {
+	L := 0
+	for L < len(q) && bytes.Contains(text, q[:L+1]) {
+		L++
+	}
+	return L
+}
+
+//-----------------------------------------------------------------------------
+// MatchingStatistics and MatchingStatisticsIn agree with a scan of the text,
+// with and without a bidirectional index.
+func TestMatchingStatistics(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 4, 800)
+	for _, bi := range []bool{false, true} {
+		I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true, Bidirectional: bi})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < 200; n++ {
+			_, _, query := random_substring(rng, records, 5+rng.Intn(50))
+			for e := rng.Intn(6); e > 0; e-- {
+				query[rng.Intn(len(query))] = "ACGTN"[rng.Intn(5)]
+			}
+			seq := rng.Intn(len(records))
+			ms, err := I.MatchingStatistics(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, err := I.MatchingStatisticsIn(query, seq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range query {
+				L := brute_match(I.SEQ, query[i:])
+				if ms[i].Len() != L {
+					t.Fatalf("bidirectional %v: %s at %d: length %d, want %d", bi, query, i, ms[i].Len(), L)
+				}
+				if r, _ := I.Search(query[i : i+L]); L > 0 && ms[i].Range() != r {
+					t.Fatalf("bidirectional %v: %s at %d: rows %v, want %v", bi, query, i, ms[i].Range(), r)
+				}
+				if L = brute_match(records[seq].Seq, query[i:]); in[i].Len() != L {
+					t.Fatalf("bidirectional %v: %s at %d in sequence %d: length %d, want %d", bi, query, i, seq, in[i].Len(), L)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// BuildOptions controls index construction.  Zero values select defaults,
+// except Ratio, which must be at least 1.
+//-----------------------------------------------------------------------------
+type BuildOptions struct {
+	File              string   // FASTA or FASTQ file storing the sequence(s), possibly gzipped
+	Multiple          bool     // true if the file contains multiple sequences
+	Ratio             int      // compression ratio, i.e. OCC sampling rate; >= 1
+	SARate            int      // suffix array sampling rate; 0 keeps only the full suffix array
+	Separator         byte     // placed between sequences; default '|'
+	Terminator        byte     // placed at the end of the text; default '$'
+	Alphabet          Alphabet // normalization of sequences and queries
+	Strict            bool     // reject malformed FASTA or FASTQ instead of fixing it (see FastaReport)
+	ReverseComplement bool     // also index the reverse complement of each sequence
+	OutputDir         string   // directory used by SaveCompressedIndex; default File + ".fmi"
+	Workers           int      // goroutines used to build and save; default runtime.NumCPU()
+	Backend           string   // rank backend, one of the BACKEND_ names; "" picks one from the alphabet
+	Bidirectional     bool     // also index the reversed text, for BiInterval
+}
+
+//-----------------------------------------------------------------------------
+// Fills in defaults and checks that the options are consistent.
+func (opts *BuildOptions) validate() error {
+	if opts.Separator == 0 {
+		opts.Separator = '|'
+	}
+	if opts.Terminator == 0 {
+		opts.Terminator = '$'
+	}
+	if err := opts.Alphabet.validate(opts.Separator, opts.Terminator); err != nil {
+		return err
+	}
+	switch {
+	case opts.Ratio < 1:
+		return fmt.Errorf("BuildOptions: Ratio must be at least 1, got %d", opts.Ratio)
+	case opts.SARate < 0:
+		return fmt.Errorf("BuildOptions: SARate must not be negative, got %d", opts.SARate)
+	case opts.Workers < 0:
+		return fmt.Errorf("BuildOptions: Workers must not be negative, got %d", opts.Workers)
+	case opts.Separator == opts.Terminator:
+		return fmt.Errorf("BuildOptions: Separator and Terminator must differ, both are %q", opts.Separator)
+	case opts.Backend != "" && opts.Backend != BACKEND_BLOCKS && opts.Backend != BACKEND_DNA && opts.Backend != BACKEND_WAVELET &&
+		opts.Backend != BACKEND_RLE:
+		return fmt.Errorf("BuildOptions: unknown Backend %q", opts.Backend)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// Reports whether c is reserved in the text: the separator, the terminator
+// and, if NBreak is set, the break symbol.  Call after validate.
+func (opts *BuildOptions) reserved(c byte) bool {
+	return c == opts.Separator || c == opts.Terminator || opts.Alphabet.NBreak > 0 && c == opts.Alphabet.Break
+}
+
+//-----------------------------------------------------------------------------
+// Number of goroutines used to build and save the index.
+func (I *IndexC) workers() int {
+	if I.Workers > 0 {
+		return I.Workers
+	}
+	return runtime.NumCPU()
+}
+
+//-----------------------------------------------------------------------------
+// Splits [0, n) into at most workers contiguous parts and calls f on each
+// part in its own goroutine; w numbers the parts from 0.
+func parallel(workers int, n indexType, f func(w int, lo, hi indexType)) {
+	size := n/indexType(workers) + 1
+	var wg sync.WaitGroup
+	for w, lo := 0, indexType(0); lo < n; w, lo = w+1, lo+size {
+		hi := lo + size
+		if hi > n {
+			hi = n
+		}
+		wg.Add(1)
+		go func(w int, lo, hi indexType) {
+			defer wg.Done()
+			f(w, lo, hi)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,288 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path"
+)
+
+// Names of the rank backends, for BuildOptions.Backend.
+const (
+	BACKEND_BLOCKS  = "blocks"  // BWT bytes interleaved with occurence counts
+	BACKEND_DNA     = "dna"     // 2-bit packed bases, other symbols in a side table
+	BACKEND_WAVELET = "wavelet" // wavelet matrix, for large alphabets
+	BACKEND_RLE     = "rle"     // runs of the BWT, for repetitive texts; never picked automatically
+)
+
+//-----------------------------------------------------------------------------
+// RankBackend stores the BWT of an index and answers rank queries on it.
+// Search, Guess and Locate only go through these operations, so backends
+// can be swapped without changing them.
+//-----------------------------------------------------------------------------
+type RankBackend interface {
+	Rank(c byte, i int) int // occurences of c in BWT[0, i)
+	Access(i int) byte      // BWT[i]
+	Sigma() int             // number of distinct symbols
+	Name() string           // one of the BACKEND_ names; saved with the index
+}
+
+// rank_backend is a RankBackend that can be saved and loaded.
+type rank_backend interface {
+	RankBackend
+	rank(c byte, i indexType) indexType
+	access(i indexType) byte
+	save(dir string) error
+	load(dir string) error // fills in a backend set up by rank_layout
+}
+
+//-----------------------------------------------------------------------------
+// Picks a backend for the index: the packed DNA backend if the alphabet
+// fits, a wavelet matrix for large alphabets, blocks of bytes otherwise.
+func (I *IndexC) auto_backend() string {
+	switch {
+	case I.fits_dna():
+		return BACKEND_DNA
+	case len(I.SYMBOLS) > WAVELET_SYMBOLS:
+		return BACKEND_WAVELET
+	}
+	return BACKEND_BLOCKS
+}
+
+//-----------------------------------------------------------------------------
+// Builds the named rank backend of bwt; "" picks one with auto_backend.
+// SYMBOLS, Freq, M and WIDTH must be set, and SA for the run-length backend.
+func (I *IndexC) new_rank(bwt []byte, name string) rank_backend {
+	if name == "" {
+		name = I.auto_backend()
+	}
+	switch name {
+	case BACKEND_DNA:
+		return new_dna_rank(bwt, I.SYMBOLS, I.M, I.WIDTH, I.workers())
+	case BACKEND_WAVELET:
+		return new_wavelet_rank(bwt, I.SYMBOLS)
+	case BACKEND_RLE:
+		return new_rle_rank(bwt, I.SA, I.SYMBOLS, I.WIDTH)
+	}
+	return new_block_rank(bwt, I.SYMBOLS, I.M, I.WIDTH, I.workers())
+}
+
+//-----------------------------------------------------------------------------
+// Sets up the backend that new_rank would build, to be filled by load.
+// Returns nil if name is unknown.
+func (I *IndexC) rank_layout(name string) rank_backend {
+	if name == "" {
+		name = I.auto_backend()
+	}
+	switch name {
+	case BACKEND_DNA:
+		return new_dna_layout(I.SYMBOLS, I.Freq, I.LEN, I.M, I.WIDTH)
+	case BACKEND_WAVELET:
+		return new_wavelet_layout(I.SYMBOLS, I.LEN)
+	case BACKEND_RLE:
+		return new_rle_layout(I.SYMBOLS, I.LEN, I.RUNS, I.WIDTH)
+	case BACKEND_BLOCKS:
+		return new_block_layout(I.SYMBOLS, I.LEN, I.M, I.WIDTH)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// The rank backend storing the BWT.
+//-----------------------------------------------------------------------------
+func (I *IndexC) Backend() RankBackend {
+	return I.bwt
+}
+
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0, i).
+func (I *IndexC) rank(c byte, i indexType) indexType {
+	return I.bwt.rank(c, i)
+}
+
+//-----------------------------------------------------------------------------
+// Counts the runs of the BWT through the backend, for indexes saved before
+// RUNS was.
+func (I *IndexC) bwt_runs() indexType {
+	var r indexType
+	for i := indexType(0); i < I.LEN; i++ {
+		if i == 0 || I.bwt.access(i) != I.bwt.access(i-1) {
+			r++
+		}
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+// The run-length backend, or nil if the index uses another one.
+func (I *IndexC) run_length() *rleRank {
+	b, _ := I.bwt.(*rleRank)
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// blockRank stores the BWT in blocks of m bytes.  Each block starts with
+// the occurences of every symbol before the block, indexed by a dense
+// symbol code, followed by the BWT bytes it covers, so that a rank query
+// reads one contiguous piece of memory.
+//-----------------------------------------------------------------------------
+type blockRank struct {
+	symbols []byte     // symbols, by code
+	code    [256]int16 // code of each symbol; -1 if it does not occur
+	m       indexType  // BWT bytes per block
+	width   int        // bytes per count: 4 or 8
+	counts  indexType  // bytes of counts per block
+	block   indexType  // bytes per block
+	n       indexType  // length of the BWT
+	data    []byte
+}
+
+//-----------------------------------------------------------------------------
+// Sets up the layout of a blockRank over a BWT of length n; data is not
+// allocated.  symbols must be sorted.
+func new_block_layout(symbols []int, n indexType, m, width int) *blockRank {
+	b := &blockRank{m: indexType(m), width: width, n: n}
+	for c := range b.code {
+		b.code[c] = -1
+	}
+	for k, c := range symbols {
+		b.symbols = append(b.symbols, byte(c))
+		b.code[c] = int16(k)
+	}
+	b.counts = indexType(len(symbols) * width)
+	b.block = b.counts + b.m
+	return b
+}
+
+// Number of blocks; the last one may be partly or entirely empty.
+func (b *blockRank) n_blocks() indexType {
+	return b.n/b.m + 1
+}
+
+//-----------------------------------------------------------------------------
+// Builds the blocks of bwt.  The symbols of each chunk of blocks are counted
+// in parallel, then each chunk is filled starting from the counts of the
+// chunks before it.
+func new_block_rank(bwt []byte, symbols []int, m, width, workers int) *blockRank {
+	b := new_block_layout(symbols, indexType(len(bwt)), m, width)
+	n_blocks := b.n_blocks()
+	b.data = make([]byte, n_blocks*b.block)
+
+	// bytes of the BWT covered by block k
+	bwt_of := func(k indexType) []byte {
+		lo, hi := k*b.m, (k+1)*b.m
+		if lo > b.n {
+			lo = b.n
+		}
+		if hi > b.n {
+			hi = b.n
+		}
+		return bwt[lo:hi]
+	}
+	per_chunk := n_blocks/indexType(workers) + 1
+	n_chunks := (n_blocks + per_chunk - 1) / per_chunk
+	counts := make([][]indexType, n_chunks+1)
+	for k := range counts {
+		counts[k] = make([]indexType, len(symbols))
+	}
+	blocks := func(k indexType) (indexType, indexType) {
+		lo, hi := k*per_chunk, (k+1)*per_chunk
+		if hi > n_blocks {
+			hi = n_blocks
+		}
+		return lo, hi
+	}
+	parallel(workers, n_chunks, func(w int, lo, hi indexType) {
+		for k := lo; k < hi; k++ {
+			first, last := blocks(k)
+			for blk := first; blk < last; blk++ {
+				for _, c := range bwt_of(blk) {
+					counts[k+1][b.code[c]]++
+				}
+			}
+		}
+	})
+	for k := indexType(1); k <= n_chunks; k++ {
+		for c := range counts[k] {
+			counts[k][c] += counts[k-1][c]
+		}
+	}
+	parallel(workers, n_chunks, func(w int, lo, hi indexType) {
+		for k := lo; k < hi; k++ {
+			count := counts[k]
+			first, last := blocks(k)
+			for blk := first; blk < last; blk++ {
+				for c, v := range count {
+					b.set_count(blk, c, v)
+				}
+				copy(b.data[blk*b.block+b.counts:], bwt_of(blk))
+				for _, c := range bwt_of(blk) {
+					count[b.code[c]]++
+				}
+			}
+		}
+	})
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// Occurences of the symbol with code c before block k.
+func (b *blockRank) count(k indexType, c int) indexType {
+	off := k*b.block + indexType(c*b.width)
+	if b.width == 4 {
+		return indexType(binary.LittleEndian.Uint32(b.data[off:]))
+	}
+	return indexType(binary.LittleEndian.Uint64(b.data[off:]))
+}
+
+func (b *blockRank) set_count(k indexType, c int, v indexType) {
+	off := k*b.block + indexType(c*b.width)
+	if b.width == 4 {
+		binary.LittleEndian.PutUint32(b.data[off:], uint32(v))
+	} else {
+		binary.LittleEndian.PutUint64(b.data[off:], uint64(v))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0, i).
+func (b *blockRank) rank(c byte, i indexType) indexType {
+	code := b.code[c]
+	if code < 0 {
+		return 0
+	}
+	k := i / b.m
+	r := b.count(k, int(code))
+	if rest := i - k*b.m; rest > 0 {
+		start := k*b.block + b.counts
+		r += indexType(bytes.Count(b.data[start:start+rest], []byte{c}))
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+// BWT[i].
+func (b *blockRank) access(i indexType) byte {
+	k := i / b.m
+	return b.data[k*b.block+b.counts+i-k*b.m]
+}
+
+func (b *blockRank) Rank(c byte, i int) int { return int(b.rank(c, indexType(i))) }
+func (b *blockRank) Access(i int) byte      { return b.access(indexType(i)) }
+func (b *blockRank) Sigma() int             { return len(b.symbols) }
+func (b *blockRank) Name() string           { return BACKEND_BLOCKS }
+
+//-----------------------------------------------------------------------------
+func (b *blockRank) save(dir string) error {
+	return ioutil.WriteFile(path.Join(dir, "rank"), b.data, 0666)
+}
+
+func (b *blockRank) load(dir string) error {
+	var err error
+	b.data, err = _load_bytes(path.Join(dir, "rank"), b.n_blocks()*b.block)
+	return err
+}
@@ -0,0 +1,181 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// Compares Search and Guess on each rank backend, and on the original
+// layout (one OCC array per symbol in a map plus a separate BWT), across
+// compression ratios.  The text is random DNA, 8 sequences of 500000 bases.
+//
+// Usage: go test -run NONE -bench 'Search|Guess'
+
+var bench_ratios = []int{1, 8, 32, 128}
+var bench_backends = []string{BACKEND_BLOCKS, BACKEND_DNA, BACKEND_WAVELET, BACKEND_RLE}
+
+var bench_data struct {
+	once    sync.Once
+	records []Record
+	queries [][]byte
+	indexes map[string]*IndexC
+	legacy  map[int]*legacy_rank
+}
+
+//-----------------------------------------------------------------------------
+// Returns the index of the benchmark text with the given backend and ratio,
+// building it the first time.
+func bench_index(b *testing.B, backend string, ratio int) *IndexC {
+	d := &bench_data
+	d.once.Do(func() {
+		rng := rand.New(rand.NewSource(1))
+		for s := 0; s < 8; s++ {
+			seq := make([]byte, 500000)
+			for i := range seq {
+				seq[i] = "ACGT"[rng.Intn(4)]
+			}
+			d.records = append(d.records, Record{fmt.Sprint("seq", s), seq})
+		}
+		for k := 0; k < 1000; k++ {
+			seq := d.records[rng.Intn(len(d.records))].Seq
+			i := rng.Intn(len(seq) - 100)
+			d.queries = append(d.queries, seq[i:i+100])
+		}
+		d.indexes = make(map[string]*IndexC)
+		d.legacy = make(map[int]*legacy_rank)
+	})
+	key := fmt.Sprint(backend, ratio)
+	if d.indexes[key] == nil {
+		b.StopTimer()
+		idx, err := BuildFromRecords(d.records, BuildOptions{Multiple: true, Ratio: ratio, Backend: backend})
+		if err != nil {
+			b.Fatal(err)
+		}
+		d.indexes[key] = idx
+		b.StartTimer()
+	}
+	return d.indexes[key]
+}
+
+//-----------------------------------------------------------------------------
+// Runs f as a sub-benchmark for every backend, the legacy layout included,
+// and ratio.
+func bench_all(b *testing.B, f func(b *testing.B, search func([]byte) (int, int), guess func([]byte) int)) {
+	for _, ratio := range bench_ratios {
+		b.Run(fmt.Sprintf("legacy/ratio=%d", ratio), func(b *testing.B) {
+			idx := bench_index(b, BACKEND_BLOCKS, ratio)
+			L := bench_data.legacy[ratio]
+			if L == nil {
+				L = new_legacy_rank(idx)
+				bench_data.legacy[ratio] = L
+			}
+			f(b, L.search, L.guess)
+		})
+		for _, backend := range bench_backends {
+			b.Run(fmt.Sprintf("%s/ratio=%d", backend, ratio), func(b *testing.B) {
+				idx := bench_index(b, backend, ratio)
+				f(b, func(q []byte) (int, int) {
+					r, _ := idx.Search(q)
+					return r.Sp, r.Ep
+				}, func(q []byte) int {
+					s, _ := idx.Guess(q, 0)
+					return s
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	bench_all(b, func(b *testing.B, search func([]byte) (int, int), guess func([]byte) int) {
+		q := bench_data.queries
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			search(q[i%len(q)])
+		}
+	})
+}
+
+func BenchmarkGuess(b *testing.B) {
+	bench_all(b, func(b *testing.B, search func([]byte) (int, int), guess func([]byte) int) {
+		q := bench_data.queries
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			guess(q[i%len(q)])
+		}
+	})
+}
+
+//-----------------------------------------------------------------------------
+// The layout before the interleaved blocks, rebuilt from an index.
+type legacy_rank struct {
+	idx *IndexC
+	bwt []byte
+	occ map[byte][]int
+	m   int
+}
+
+func new_legacy_rank(idx *IndexC) *legacy_rank {
+	L := &legacy_rank{idx: idx, bwt: make([]byte, idx.LEN), occ: make(map[byte][]int), m: idx.M}
+	count := make(map[byte]int)
+	for _, c := range idx.SYMBOLS {
+		L.occ[byte(c)] = make([]int, idx.OCC_SIZE)
+	}
+	for j := 0; j < int(idx.LEN); j++ {
+		L.bwt[j] = idx.Access(j)
+		count[L.bwt[j]]++
+		if j%L.m == 0 {
+			for c := range L.occ {
+				L.occ[c][j/L.m] = count[c]
+			}
+		}
+	}
+	return L
+}
+
+func (L *legacy_rank) occurence(c byte, pos int) int {
+	i := pos / L.m
+	count := L.occ[c][i]
+	for j := i*L.m + 1; j <= pos; j++ {
+		if L.bwt[j] == c {
+			count += 1
+		}
+	}
+	return count
+}
+
+func (L *legacy_rank) search(query []byte) (int, int) {
+	c := query[len(query)-1]
+	sp, ep := int(L.idx.C[c]), int(L.idx.EP[c])
+	for i := len(query) - 2; sp <= ep && i >= 0; i-- {
+		c = query[i]
+		sp = int(L.idx.C[c]) + L.occurence(c, sp-1)
+		ep = int(L.idx.C[c]) + L.occurence(c, ep) - 1
+	}
+	return sp, ep
+}
+
+func (L *legacy_rank) guess(query []byte) int {
+	c := query[len(query)-1]
+	sp, ep := int(L.idx.C[c]), int(L.idx.EP[c])
+	for i := len(query) - 2; sp < ep && i >= 0; i-- {
+		c = query[i]
+		sp = int(L.idx.C[c]) + L.occurence(c, sp-1)
+		ep = int(L.idx.C[c]) + L.occurence(c, ep) - 1
+	}
+	if sp > ep {
+		return -1
+	}
+	for j := sp + 1; j <= ep; j++ {
+		if L.idx.SSA.Get(j) != L.idx.SSA.Get(sp) {
+			return -1
+		}
+	}
+	return int(L.idx.SSA.Get(sp))
+}
@@ -0,0 +1,247 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"math/bits"
+	"path"
+	"sort"
+)
+
+// Symbols other than A, C, G and T may make up at most 1/DNA_EXCEPTIONS of
+// the text for the packed DNA backend to be used.
+const DNA_EXCEPTIONS = 32
+
+// 2-bit codes of the bases; every other symbol is an exception.
+var dna_code = func() [256]int8 {
+	var t [256]int8
+	for c := range t {
+		t[c] = -1
+	}
+	t['A'], t['C'], t['G'], t['T'] = 0, 1, 2, 3
+	return t
+}()
+
+const dna_bases = "ACGT"
+
+//-----------------------------------------------------------------------------
+// True if the BWT is mostly A, C, G and T, so that the packed DNA backend
+// can store it.
+func (I *IndexC) fits_dna() bool {
+	var exceptions indexType
+	for _, c := range I.SYMBOLS {
+		if dna_code[c] < 0 {
+			exceptions += I.Freq[byte(c)]
+		}
+	}
+	return exceptions <= I.LEN/DNA_EXCEPTIONS
+}
+
+//-----------------------------------------------------------------------------
+// dnaRank packs the BWT into 2-bit codes, 32 per word, and answers rank
+// with popcount.  Like blockRank, every block starts with the counts of the
+// four codes before it.  Other symbols, such as the terminator, separators
+// and N's, are stored as A in the packed words and listed with their
+// positions in a side table, which rank and access consult.
+//-----------------------------------------------------------------------------
+type dnaRank struct {
+	m          indexType // symbols per block, a multiple of 32
+	width      int       // bytes per count: 4 or 8
+	counts     indexType // words of counts per block
+	block      indexType // words per block
+	n          indexType // length of the BWT
+	sigma      int       // number of distinct symbols
+	data       []uint64
+	exc_symbol []byte        // exceptional symbols, sorted
+	exc_pos    [][]indexType // sorted positions of each exceptional symbol
+}
+
+//-----------------------------------------------------------------------------
+// Sets up the layout of a dnaRank over a BWT of length n; data and the
+// exception lists are not filled in.  Blocks hold the M symbols of the
+// compression ratio rounded up to a whole number of words.
+func new_dna_layout(symbols []int, freq map[byte]indexType, n indexType, m, width int) *dnaRank {
+	b := &dnaRank{width: width, n: n, sigma: len(symbols)}
+	b.m = (indexType(m) + 31) / 32 * 32
+	b.counts = indexType(width / 2)
+	b.block = b.counts + b.m/32
+	for _, c := range symbols {
+		if dna_code[c] < 0 {
+			b.exc_symbol = append(b.exc_symbol, byte(c))
+			b.exc_pos = append(b.exc_pos, make([]indexType, 0, freq[byte(c)]))
+		}
+	}
+	return b
+}
+
+func (b *dnaRank) n_blocks() indexType {
+	return b.n/b.m + 1
+}
+
+//-----------------------------------------------------------------------------
+// Packs bwt in parallel, then fills in the counts and the exceptions.
+func new_dna_rank(bwt []byte, symbols []int, m, width, workers int) *dnaRank {
+	freq := make(map[byte]indexType)
+	for _, c := range bwt {
+		if dna_code[c] < 0 {
+			freq[c]++
+		}
+	}
+	b := new_dna_layout(symbols, freq, indexType(len(bwt)), m, width)
+	n_blocks := b.n_blocks()
+	b.data = make([]uint64, n_blocks*b.block)
+	parallel(workers, n_blocks, func(w int, lo, hi indexType) {
+		for k := lo; k < hi; k++ {
+			for i := k * b.m; i < (k+1)*b.m && i < b.n; i++ {
+				if code := dna_code[bwt[i]]; code > 0 {
+					off := i - k*b.m
+					b.data[k*b.block+b.counts+off/32] |= uint64(code) << (2 * (off % 32))
+				}
+			}
+		}
+	})
+
+	var count [4]indexType
+	for k := indexType(0); k < n_blocks; k++ {
+		for c := range count {
+			b.set_count(k, c, count[c])
+		}
+		for _, word := range b.data[k*b.block+b.counts : (k+1)*b.block] {
+			for c := range count {
+				count[c] += indexType(bits.OnesCount64(dna_matches(word, c)))
+			}
+		}
+	}
+	// the padding at the end of the last block reads as A; it is never
+	// counted because rank stops at i <= n
+
+	for i, c := range bwt {
+		if dna_code[c] < 0 {
+			e := sort.Search(len(b.exc_symbol), func(k int) bool { return b.exc_symbol[k] >= c })
+			b.exc_pos[e] = append(b.exc_pos[e], indexType(i))
+		}
+	}
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// Bit 2j of the result is set if the j-th code of word is c.
+func dna_matches(word uint64, c int) uint64 {
+	x := word ^ (uint64(c) * 0x5555555555555555)
+	return ^(x | x>>1) & 0x5555555555555555
+}
+
+//-----------------------------------------------------------------------------
+// Count of code c before block k.
+func (b *dnaRank) count(k indexType, c int) indexType {
+	word := b.data[k*b.block+indexType(c*b.width/8)]
+	if b.width == 4 {
+		return indexType(word >> (32 * uint(c%2)) & 0xffffffff)
+	}
+	return indexType(word)
+}
+
+func (b *dnaRank) set_count(k indexType, c int, v indexType) {
+	p := &b.data[k*b.block+indexType(c*b.width/8)]
+	if b.width == 4 {
+		shift := 32 * uint(c%2)
+		*p = *p&^(0xffffffff<<shift) | uint64(v)<<shift
+	} else {
+		*p = uint64(v)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Number of positions of exception e before i.
+func (b *dnaRank) exc_rank(e int, i indexType) indexType {
+	pos := b.exc_pos[e]
+	return indexType(sort.Search(len(pos), func(k int) bool { return pos[k] >= i }))
+}
+
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0, i).
+func (b *dnaRank) rank(c byte, i indexType) indexType {
+	code := int(dna_code[c])
+	if code < 0 {
+		for e, s := range b.exc_symbol {
+			if s == c {
+				return b.exc_rank(e, i)
+			}
+		}
+		return 0
+	}
+	k := i / b.m
+	r := b.count(k, code)
+	words := b.data[k*b.block+b.counts : (k+1)*b.block]
+	rest := i - k*b.m
+	for _, word := range words[:rest/32] {
+		r += indexType(bits.OnesCount64(dna_matches(word, code)))
+	}
+	if rest%32 > 0 {
+		mask := uint64(1)<<(2*uint(rest%32)) - 1
+		r += indexType(bits.OnesCount64(dna_matches(words[rest/32], code) & mask))
+	}
+	if code == 0 {
+		// exceptions are packed as A
+		for e := range b.exc_symbol {
+			r -= b.exc_rank(e, i)
+		}
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+// BWT[i].
+func (b *dnaRank) access(i indexType) byte {
+	k := i / b.m
+	off := i - k*b.m
+	code := b.data[k*b.block+b.counts+off/32] >> (2 * (off % 32)) & 3
+	if code == 0 {
+		for e, pos := range b.exc_pos {
+			if j := b.exc_rank(e, i); j < indexType(len(pos)) && pos[j] == i {
+				return b.exc_symbol[e]
+			}
+		}
+	}
+	return dna_bases[code]
+}
+
+func (b *dnaRank) Rank(c byte, i int) int { return int(b.rank(c, indexType(i))) }
+func (b *dnaRank) Access(i int) byte      { return b.access(indexType(i)) }
+func (b *dnaRank) Sigma() int             { return b.sigma }
+func (b *dnaRank) Name() string           { return BACKEND_DNA }
+
+//-----------------------------------------------------------------------------
+// The packed words go to "rank" and the positions of the exceptions, one
+// symbol after the other, to "rank_exceptions".
+func (b *dnaRank) save(dir string) error {
+	if err := _save_binary(b.data, path.Join(dir, "rank")); err != nil {
+		return err
+	}
+	var pos []indexType
+	for _, p := range b.exc_pos {
+		pos = append(pos, p...)
+	}
+	return _save_binary(pos, path.Join(dir, "rank_exceptions"))
+}
+
+func (b *dnaRank) load(dir string) error {
+	b.data = make([]uint64, b.n_blocks()*b.block)
+	if err := _load_binary(path.Join(dir, "rank"), b.data); err != nil {
+		return err
+	}
+	var total int
+	for _, p := range b.exc_pos {
+		total += cap(p)
+	}
+	pos := make([]indexType, total)
+	if err := _load_binary(path.Join(dir, "rank_exceptions"), pos); err != nil {
+		return err
+	}
+	for e, p := range b.exc_pos {
+		b.exc_pos[e], pos = pos[:cap(p)], pos[cap(p):]
+	}
+	return nil
+}
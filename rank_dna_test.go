@@ -0,0 +1,22 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"math/rand"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// The packed DNA backend agrees with the BWT on bases, and on the symbols it
+// keeps in its side table.
+func TestDNABackend(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	check_backend(t, rng, BACKEND_DNA, random_strains(rng, 4, 1000))
+	check_backend(t, rng, BACKEND_DNA, []Record{
+		{"mixed", random_seq(rng, 3000, "ACGTACGTACGTNacgtRY")},
+		{"short", []byte("ACGTTGCANNACGT")},
+	})
+}
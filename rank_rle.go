@@ -0,0 +1,223 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"path"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+// rleRank stores the BWT as r runs of equal symbols, and the suffix array
+// values at the first and last row of each run, so that it takes O(r)
+// space.  Rank takes two binary searches.  Locating uses the r-index
+// method: backward search carries the suffix array value of its last row
+// (the toehold), and the other rows of the range are found with φ, which
+// maps SA[i] to SA[i-1].
+//-----------------------------------------------------------------------------
+type rleRank struct {
+	symbols  []byte     // symbols, by code
+	code     [256]int16 // code of each symbol; -1 if it does not occur
+	n        indexType  // length of the BWT
+	r        indexType  // number of runs
+	starts   UintArray  // first row of each run
+	heads    []byte     // symbol of each run
+	start_sa UintArray  // SA at the first row of each run
+	end_sa   UintArray  // SA at the last row of each run
+
+	// built from the above
+	runs_of  [][]indexType // runs of each symbol, by code
+	cum_of   [][]indexType // cum_of[c][j]: length of the first j runs of symbol c
+	phi_key  []indexType   // SA at the first row of runs 1 to r-1, sorted
+	phi_prev []indexType   // SA at the last row of the run before
+}
+
+//-----------------------------------------------------------------------------
+// Sets up an rleRank with r runs over a BWT of length n; the runs and the
+// samples are not filled in.
+func new_rle_layout(symbols []int, n, r indexType, width int) *rleRank {
+	b := &rleRank{n: n, r: r}
+	for c := range b.code {
+		b.code[c] = -1
+	}
+	for k, c := range symbols {
+		b.symbols = append(b.symbols, byte(c))
+		b.code[c] = int16(k)
+	}
+	b.starts = newUintArray(width, r)
+	b.heads = make([]byte, r)
+	b.start_sa = newUintArray(width, r)
+	b.end_sa = newUintArray(width, r)
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// Number of runs in bwt.
+func count_runs(bwt []byte) indexType {
+	var r indexType
+	for i := range bwt {
+		if i == 0 || bwt[i] != bwt[i-1] {
+			r++
+		}
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+func new_rle_rank(bwt []byte, sa UintArray, symbols []int, width int) *rleRank {
+	b := new_rle_layout(symbols, indexType(len(bwt)), count_runs(bwt), width)
+	k := indexType(-1)
+	for i := range bwt {
+		if i == 0 || bwt[i] != bwt[i-1] {
+			k++
+			put(b.starts, k, indexType(i))
+			b.heads[k] = bwt[i]
+			b.start_sa.Set(int(k), sa.Get(i))
+		}
+		b.end_sa.Set(int(k), sa.Get(i))
+	}
+	b.finish()
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// Builds the run lists of each symbol and φ from the runs and samples.
+func (b *rleRank) finish() {
+	b.runs_of = make([][]indexType, len(b.symbols))
+	b.cum_of = make([][]indexType, len(b.symbols))
+	for c := range b.cum_of {
+		b.cum_of[c] = []indexType{0}
+	}
+	for k := indexType(0); k < b.r; k++ {
+		c := b.code[b.heads[k]]
+		cum := b.cum_of[c]
+		b.runs_of[c] = append(b.runs_of[c], k)
+		b.cum_of[c] = append(cum, cum[len(cum)-1]+b.run_length(k))
+	}
+
+	b.phi_key = make([]indexType, 0, b.r)
+	b.phi_prev = make([]indexType, 0, b.r)
+	order := make([]indexType, 0, b.r)
+	for k := indexType(1); k < b.r; k++ {
+		order = append(order, k)
+	}
+	sort.Slice(order, func(x, y int) bool { return at(b.start_sa, order[x]) < at(b.start_sa, order[y]) })
+	for _, k := range order {
+		b.phi_key = append(b.phi_key, at(b.start_sa, k))
+		b.phi_prev = append(b.phi_prev, at(b.end_sa, k-1))
+	}
+}
+
+func (b *rleRank) run_length(k indexType) indexType {
+	if k+1 < b.r {
+		return at(b.starts, k+1) - at(b.starts, k)
+	}
+	return b.n - at(b.starts, k)
+}
+
+//-----------------------------------------------------------------------------
+// The run containing row i.
+func (b *rleRank) run(i indexType) indexType {
+	return indexType(sort.Search(int(b.r), func(k int) bool { return at(b.starts, indexType(k)) > i })) - 1
+}
+
+//-----------------------------------------------------------------------------
+// Number of runs of the symbol with code c before run k.
+func (b *rleRank) runs_before(c int, k indexType) int {
+	runs := b.runs_of[c]
+	return sort.Search(len(runs), func(j int) bool { return runs[j] >= k })
+}
+
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0, i).
+func (b *rleRank) rank(c byte, i indexType) indexType {
+	code := int(b.code[c])
+	if code < 0 || i == 0 {
+		return 0
+	}
+	k := b.run(i - 1)
+	r := b.cum_of[code][b.runs_before(code, k)]
+	if b.heads[k] == c {
+		r += i - at(b.starts, k)
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+// BWT[i].
+func (b *rleRank) access(i indexType) byte {
+	return b.heads[b.run(i)]
+}
+
+//-----------------------------------------------------------------------------
+// The toehold of the rows c+P, given the rows [sp, ep] of P, t = SA[ep],
+// and that c occurs in BWT[sp..ep].  If BWT[ep] is not c, the last c in
+// the range ends a run, whose last SA value is sampled.
+func (b *rleRank) toehold(c byte, sp, ep, t indexType) indexType {
+	k := b.run(ep)
+	if b.heads[k] != c {
+		runs := b.runs_of[b.code[c]]
+		t = at(b.end_sa, runs[b.runs_before(int(b.code[c]), k)-1])
+	}
+	if t == 0 {
+		return b.n - 1
+	}
+	return t - 1
+}
+
+//-----------------------------------------------------------------------------
+// SA[i-1] from p = SA[i], for i > 0.  The predecessor of p among the
+// samples at the first rows of runs gives the answer up to the offset.
+func (b *rleRank) phi(p indexType) indexType {
+	j := sort.Search(len(b.phi_key), func(j int) bool { return b.phi_key[j] > p }) - 1
+	return b.phi_prev[j] + p - b.phi_key[j]
+}
+
+// SA at the last row of the BWT, the toehold of the empty pattern.
+func (b *rleRank) last_sa() indexType {
+	return at(b.end_sa, b.r-1)
+}
+
+func (b *rleRank) Rank(c byte, i int) int { return int(b.rank(c, indexType(i))) }
+func (b *rleRank) Access(i int) byte      { return b.access(indexType(i)) }
+func (b *rleRank) Sigma() int             { return len(b.symbols) }
+func (b *rleRank) Name() string           { return BACKEND_RLE }
+
+//-----------------------------------------------------------------------------
+// The first row of each run goes to "rank", the symbols to "rank_heads"
+// and the SA samples to "rank_samples", first rows then last rows.
+func (b *rleRank) save(dir string) error {
+	if err := _save_binary(b.starts.raw(), path.Join(dir, "rank")); err != nil {
+		return err
+	}
+	if err := _save_binary(b.heads, path.Join(dir, "rank_heads")); err != nil {
+		return err
+	}
+	samples := newUintArray(b.starts.Width(), 2*b.r)
+	for k := 0; k < int(b.r); k++ {
+		samples.Set(k, b.start_sa.Get(k))
+		samples.Set(int(b.r)+k, b.end_sa.Get(k))
+	}
+	return _save_binary(samples.raw(), path.Join(dir, "rank_samples"))
+}
+
+func (b *rleRank) load(dir string) error {
+	if err := _load_binary(path.Join(dir, "rank"), b.starts.raw()); err != nil {
+		return err
+	}
+	if err := _load_binary(path.Join(dir, "rank_heads"), b.heads); err != nil {
+		return err
+	}
+	samples := newUintArray(b.starts.Width(), 2*b.r)
+	if err := _load_binary(path.Join(dir, "rank_samples"), samples.raw()); err != nil {
+		return err
+	}
+	for k := 0; k < int(b.r); k++ {
+		b.start_sa.Set(k, samples.Get(k))
+		b.end_sa.Set(k, samples.Get(int(b.r)+k))
+	}
+	b.finish()
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// The run-length backend agrees with the BWT, and without the suffix array
+// locates with phi: all hits in suffix array order, or the first rows of the
+// range when limited, as with the suffix array, and Guess gives the same
+// sequences.
+func TestRLEBackend(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 8, 1000)
+	check_backend(t, rng, BACKEND_RLE, records)
+
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true, Backend: BACKEND_RLE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries [][]byte
+	var all [][]Hit
+	var guesses []int
+	for n := 0; n < 100; n++ {
+		_, _, q := random_substring(rng, records, 1+rng.Intn(40))
+		queries = append(queries, q)
+		all = append(all, I.Locate(q, 0))
+		s, _ := I.Guess(q, 0)
+		guesses = append(guesses, s)
+	}
+	I.SA = nil
+	for n, q := range queries {
+		if hits := I.Locate(q, 0); !reflect.DeepEqual(hits, all[n]) {
+			t.Fatalf("%s: located %v without the suffix array, want %v", q, hits, all[n])
+		}
+		for _, limit := range []int{1, 3, len(all[n]), len(all[n]) + 1} {
+			want := all[n]
+			if len(want) > limit {
+				want = want[:limit]
+			}
+			if hits := I.Locate(q, limit); !reflect.DeepEqual(hits, want) {
+				t.Fatalf("%s: limit %d located %v, want %v", q, limit, hits, want)
+			}
+		}
+		if s, _ := I.Guess(q, 0); s != guesses[n] {
+			t.Fatalf("%s: guessed sequence %d without the suffix array, want %d", q, s, guesses[n])
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"path"
+)
+
+// Alphabets with more than WAVELET_SYMBOLS symbols, such as proteins, are
+// stored in a wavelet matrix unless they fit the packed DNA backend.
+const WAVELET_SYMBOLS = 16
+
+//-----------------------------------------------------------------------------
+// waveletRank stores the BWT as a wavelet matrix: the dense code of each
+// symbol is split into bits, one bit vector per bit from the most
+// significant one, and each level is stably sorted by the bit of the level
+// before.  It takes log(σ) bits per symbol, plus the rank directories,
+// and rank and access take log(σ) bit vector ranks.
+//-----------------------------------------------------------------------------
+type waveletRank struct {
+	symbols []byte       // symbols, by code
+	code    [256]int16   // code of each symbol; -1 if it does not occur
+	levels  []*bitVector // their bits share words
+	words   []uint64
+	zeros   []indexType // zeros in each level
+	begin   []indexType // row of the first occurence of each code in the last level
+	n       indexType
+}
+
+//-----------------------------------------------------------------------------
+// Sets up a wavelet matrix over a BWT of length n; the levels are not
+// filled in.
+func new_wavelet_layout(symbols []int, n indexType) *waveletRank {
+	b := &waveletRank{n: n}
+	for c := range b.code {
+		b.code[c] = -1
+	}
+	for k, c := range symbols {
+		b.symbols = append(b.symbols, byte(c))
+		b.code[c] = int16(k)
+	}
+	n_levels := 1
+	for 1<<uint(n_levels) < len(symbols) {
+		n_levels++
+	}
+	size := int((n + 63) / 64)
+	b.words = make([]uint64, n_levels*size)
+	for l := 0; l < n_levels; l++ {
+		b.levels = append(b.levels, &bitVector{bits: b.words[l*size : (l+1)*size], n: n})
+	}
+	return b
+}
+
+//-----------------------------------------------------------------------------
+func new_wavelet_rank(bwt []byte, symbols []int) *waveletRank {
+	b := new_wavelet_layout(symbols, indexType(len(bwt)))
+	cur := make([]byte, len(bwt))
+	next := make([]byte, len(bwt))
+	for i, c := range bwt {
+		cur[i] = byte(b.code[c])
+	}
+	for l, level := range b.levels {
+		shift := uint(len(b.levels) - 1 - l)
+		z := 0
+		for i, x := range cur {
+			if x>>shift&1 == 0 {
+				next[z] = x
+				z++
+			} else {
+				level.set(indexType(i))
+			}
+		}
+		for _, x := range cur {
+			if x>>shift&1 == 1 {
+				next[z] = x
+				z++
+			}
+		}
+		cur, next = next, cur
+	}
+	b.finish()
+	return b
+}
+
+//-----------------------------------------------------------------------------
+// Builds the rank directories, the zero counts and the first row of each
+// code once the levels are filled in.
+func (b *waveletRank) finish() {
+	b.zeros = make([]indexType, len(b.levels))
+	for l, level := range b.levels {
+		level.build_rank()
+		b.zeros[l] = b.n - level.rank1(b.n)
+	}
+	b.begin = make([]indexType, len(b.symbols))
+	for x := range b.begin {
+		var start indexType
+		for l, level := range b.levels {
+			start = b.step(l, level, x, start)
+		}
+		b.begin[x] = start
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Maps position i of level l to the next level, following the bit of code
+// x at that level.
+func (b *waveletRank) step(l int, level *bitVector, x int, i indexType) indexType {
+	if x>>uint(len(b.levels)-1-l)&1 == 0 {
+		return i - level.rank1(i)
+	}
+	return b.zeros[l] + level.rank1(i)
+}
+
+//-----------------------------------------------------------------------------
+// Number of occurences of c in BWT[0, i).
+func (b *waveletRank) rank(c byte, i indexType) indexType {
+	x := int(b.code[c])
+	if x < 0 {
+		return 0
+	}
+	for l, level := range b.levels {
+		i = b.step(l, level, x, i)
+	}
+	return i - b.begin[x]
+}
+
+//-----------------------------------------------------------------------------
+// BWT[i].
+func (b *waveletRank) access(i indexType) byte {
+	x := 0
+	for l, level := range b.levels {
+		x <<= 1
+		if level.get(i) {
+			x |= 1
+			i = b.zeros[l] + level.rank1(i)
+		} else {
+			i -= level.rank1(i)
+		}
+	}
+	return b.symbols[x]
+}
+
+func (b *waveletRank) Rank(c byte, i int) int { return int(b.rank(c, indexType(i))) }
+func (b *waveletRank) Access(i int) byte      { return b.access(indexType(i)) }
+func (b *waveletRank) Sigma() int             { return len(b.symbols) }
+func (b *waveletRank) Name() string           { return BACKEND_WAVELET }
+
+//-----------------------------------------------------------------------------
+// The levels go to "rank", one after the other.
+func (b *waveletRank) save(dir string) error {
+	return _save_binary(b.words, path.Join(dir, "rank"))
+}
+
+func (b *waveletRank) load(dir string) error {
+	if err := _load_binary(path.Join(dir, "rank"), b.words); err != nil {
+		return err
+	}
+	b.finish()
+	return nil
+}
@@ -0,0 +1,30 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"math/rand"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// The wavelet matrix agrees with the BWT on DNA and on a protein alphabet,
+// which is the one it is picked for.
+func TestWaveletBackend(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	check_backend(t, rng, BACKEND_WAVELET, random_strains(rng, 4, 1000))
+	protein := []Record{
+		{"p1", random_seq(rng, 2000, "ACDEFGHIKLMNPQRSTVWY")},
+		{"p2", random_seq(rng, 1500, "ACDEFGHIKLMNPQRSTVWYXBZ")},
+	}
+	check_backend(t, rng, BACKEND_WAVELET, protein)
+	I, err := BuildFromRecords(protein, BuildOptions{Ratio: 8, Multiple: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if I.Backend().Name() != BACKEND_WAVELET {
+		t.Fatalf("protein index built with %s", I.Backend().Name())
+	}
+}
@@ -0,0 +1,77 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+// Complement of each base, including IUPAC codes; other symbols, such as
+// breaks, are their own complement.
+var complement = func() [256]byte {
+	var t [256]byte
+	for i := range t {
+		t[i] = byte(i)
+	}
+	pairs := []string{"AT", "CG", "RY", "KM", "BV", "DH"}
+	for _, p := range pairs {
+		t[p[0]], t[p[1]] = p[1], p[0]
+		t[p[0]+'a'-'A'], t[p[1]+'a'-'A'] = p[1]+'a'-'A', p[0]+'a'-'A'
+	}
+	t['U'], t['u'] = 'A', 'a'
+	return t
+}()
+
+//-----------------------------------------------------------------------------
+// Returns the reverse complement of seq.
+//-----------------------------------------------------------------------------
+func ReverseComplement(seq []byte) []byte {
+	rc := make([]byte, len(seq))
+	for i, c := range seq {
+		rc[len(seq)-1-i] = complement[c]
+	}
+	return rc
+}
+
+//-----------------------------------------------------------------------------
+// Appends the reverse complement of every sequence read so far to the text,
+// in the same order.  The reverse strand of sequence s is stored as internal
+// sequence s+len(LENS); LENS and GENOME_ID only describe the forward strand.
+func (I *IndexC) append_reverse_complements() {
+	var p indexType
+	for s := range I.LENS {
+		I.SEQ = append(I.SEQ, I.SEP)
+		for i := p + I.LENS[s] - 1; i >= p; i-- {
+			I.SEQ = append(I.SEQ, complement[I.SEQ[i]])
+		}
+		p += I.LENS[s] + 1
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Number of sequences stored in the text, counting reverse strands.
+func (I *IndexC) n_stored() int {
+	if I.ReverseComplement {
+		return 2 * len(I.LENS)
+	}
+	return len(I.LENS)
+}
+
+//-----------------------------------------------------------------------------
+// Forward sequence of an internal sequence number.
+func (I *IndexC) forward_id(sid int) int {
+	if sid >= len(I.LENS) {
+		return sid - len(I.LENS)
+	}
+	return sid
+}
+
+//-----------------------------------------------------------------------------
+// Maps a hit of length m on an internal sequence to the forward strand.  An
+// occurrence on the reverse strand is reported at the forward position of
+// its reverse complement.
+func (I *IndexC) forward_hit(h Hit, m int) Hit {
+	if h.SeqID < len(I.LENS) {
+		return h
+	}
+	s := h.SeqID - len(I.LENS)
+	return Hit{SeqID: s, Offset: int(I.LENS[s]) - h.Offset - m, Reverse: true}
+}
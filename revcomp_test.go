@@ -0,0 +1,143 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// Hits of q on both strands of records, found by scanning each record for q
+// and for its reverse complement, sorted by sequence, offset and strand.
+func brute_strand_hits(records []Record, q []byte) []Hit {
+	hits := []Hit{}
+	rc := ReverseComplement(q)
+	for s, r := range records {
+		for p := 0; p+len(q) <= len(r.Seq); p++ {
+			if bytes.Equal(r.Seq[p:p+len(q)], q) {
+				hits = append(hits, Hit{s, p, false})
+			}
+			if bytes.Equal(r.Seq[p:p+len(q)], rc) {
+				hits = append(hits, Hit{s, p, true})
+			}
+		}
+	}
+	sort_hits(hits)
+	return hits
+}
+
+func sort_hits(hits []Hit) {
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].SeqID != hits[b].SeqID {
+			return hits[a].SeqID < hits[b].SeqID
+		}
+		if hits[a].Offset != hits[b].Offset {
+			return hits[a].Offset < hits[b].Offset
+		}
+		return !hits[a].Reverse && hits[b].Reverse
+	})
+}
+
+//-----------------------------------------------------------------------------
+func TestReverseComplement(t *testing.T) {
+	if rc := ReverseComplement([]byte("ACGTRYKMBVDHNacgtu#")); string(rc) != "#aacgtNDHBVKMRYACGT" {
+		t.Fatalf("reverse complement is %s", rc)
+	}
+	if rc := ReverseComplement(nil); len(rc) != 0 {
+		t.Fatalf("reverse complement of nothing is %s", rc)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// With ReverseComplement, Search counts and Locate finds the occurrences of
+// a query and of its reverse complement, the latter as Reverse hits at the
+// forward position of the reverse complement, on every backend and after
+// saving and loading.
+func TestRevcompLocate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 3, 600)
+	records = append(records, Record{"short", []byte("ACGTTGCANNACGT")})
+	for _, opts := range []BuildOptions{
+		{Ratio: 8, Multiple: true, ReverseComplement: true},
+		{Ratio: 8, Multiple: true, ReverseComplement: true, SARate: 4},
+		{Ratio: 8, Multiple: true, ReverseComplement: true, Backend: BACKEND_RLE},
+		{Ratio: 8, Multiple: true, ReverseComplement: true, Backend: BACKEND_WAVELET},
+	} {
+		I, err := BuildFromRecords(records, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes := map[string]*IndexC{"built": I}
+		for option := 0; option <= 3; option++ {
+			dir := t.TempDir()
+			if err := I.Save(dir, option); err != nil {
+				t.Fatal(err)
+			}
+			if indexes[fmt.Sprint("saved with option ", option)], err = Load(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for name, X := range indexes {
+			for n := 0; n < 100; n++ {
+				_, _, q := random_substring(rng, records, 1+rng.Intn(12))
+				if rng.Intn(2) == 0 {
+					q = ReverseComplement(q)
+				}
+				want := brute_strand_hits(records, q)
+				if r, err := X.Search(q); err != nil || r.Count() != len(want) {
+					t.Fatalf("%s %s: %s found in %d rows, error %v; want %d", X.Backend().Name(), name, q, r.Count(), err, len(want))
+				}
+				hits := X.Locate(q, 0)
+				sort_hits(hits)
+				if !reflect.DeepEqual(hits, want) {
+					t.Fatalf("%s %s: %s located at %v, want %v", X.Backend().Name(), name, q, hits, want)
+				}
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Guess and GuessPair find the sequence of reads from either strand, and
+// of pairs whose mates come from opposite strands, as in paired-end data.
+func TestRevcompGuess(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	var records []Record
+	for s := 0; s < 4; s++ {
+		records = append(records, Record{fmt.Sprint("chr", s), random_seq(rng, 3000, "ACGT")})
+	}
+	I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true, ReverseComplement: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := I.Save(dir, 3); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, X := range map[string]*IndexC{"built": I, "saved": saved} {
+		for n := 0; n < 50; n++ {
+			s, p, read := random_substring(rng, records, 400)
+			rc := ReverseComplement(read[:100])
+			if seq, count := X.Guess(rc, 0); seq != s || count != 1 {
+				t.Fatalf("%s: reverse complemented read from %d:%d guessed as %d with count %d", name, s, p, seq, count)
+			}
+			if seq, _ := X.Guess(read[:100], 0); seq != s {
+				t.Fatalf("%s: read from %d:%d guessed as %d", name, s, p, seq)
+			}
+			if seq := X.GuessPair(read[:100], ReverseComplement(read[300:]), 10, 400); seq != s {
+				t.Fatalf("%s: pair from %d:%d guessed as %d", name, s, p, seq)
+			}
+		}
+	}
+}
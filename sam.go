@@ -0,0 +1,116 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// Aligns every FASTQ read from r and writes the alignments to w as SAM,
+// header first, one line per read.
+//-----------------------------------------------------------------------------
+func (I *IndexC) AlignReads(r io.Reader, w io.Writer, opts AlignOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(w)
+	if err := I.WriteSAMHeader(out); err != nil {
+		return err
+	}
+	fq := NewFastqReader(r)
+	for {
+		rec, err := fq.Read()
+		if err == io.EOF {
+			return out.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := out.WriteString(I.SAMLine(rec, I.align(rec.Seq, &opts)) + "\n"); err != nil {
+			return err
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Writes the SAM header: one @SQ line per sequence, named by the first word
+// of its GENOME_ID and made unique (see sam_name).
+//-----------------------------------------------------------------------------
+func (I *IndexC) WriteSAMHeader(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "@HD\tVN:1.6\tSO:unsorted\n"); err != nil {
+		return err
+	}
+	for s := range I.LENS {
+		if _, err := fmt.Fprintf(w, "@SQ\tSN:%s\tLN:%d\n", I.sam_name(s), I.LENS[s]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "@PG\tID:fmic\tPN:fmic\n")
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// Returns the SAM line, without a newline, of read rec aligned at a.  The
+// read is reverse complemented if it aligns to the reverse strand.
+//-----------------------------------------------------------------------------
+func (I *IndexC) SAMLine(rec *FastqRecord, a Alignment) string {
+	name := "*"
+	if f := strings.Fields(rec.Name); len(f) > 0 {
+		name = f[0]
+	}
+	seq, qual := string(rec.Seq), string(rec.Qual)
+	if len(seq) == 0 {
+		seq = "*"
+	}
+	if len(qual) == 0 {
+		qual = "*"
+	}
+	if a.SeqID < 0 {
+		return fmt.Sprintf("%s\t4\t*\t0\t0\t*\t*\t0\t0\t%s\t%s", name, seq, qual)
+	}
+	flag := 0
+	if a.Reverse {
+		flag = 16
+		seq = string(ReverseComplement(rec.Seq))
+		if qual != "*" {
+			q := []byte(qual)
+			for l, r := 0, len(q)-1; l < r; l, r = l+1, r-1 {
+				q[l], q[r] = q[r], q[l]
+			}
+			qual = string(q)
+		}
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%d\t%d\t%s\t*\t0\t0\t%s\t%s\tNM:i:%d\tAS:i:%d\tXS:i:%d",
+		name, flag, I.sam_name(a.SeqID), a.Pos+1, a.MapQ, a.Cigar, seq, qual, a.NM, a.Score, a.Sub)
+}
+
+//-----------------------------------------------------------------------------
+// SAM reference name of sequence s: the first word of its GENOME_ID, or
+// seq<s> without one.  A name already taken by an earlier sequence gets a
+// suffix _2, _3, ... so that the @SQ lines are unique.
+func (I *IndexC) sam_name(s int) string {
+	I.sam_once.Do(func() {
+		used := make(map[string]bool)
+		for s := range I.LENS {
+			name := fmt.Sprintf("seq%d", s)
+			if s < len(I.GENOME_ID) {
+				if f := strings.Fields(I.GENOME_ID[s]); len(f) > 0 {
+					name = f[0]
+				}
+			}
+			unique := name
+			for k := 2; used[unique]; k++ {
+				unique = fmt.Sprintf("%s_%d", name, k)
+			}
+			used[unique] = true
+			I.sam_names = append(I.sam_names, unique)
+		}
+	})
+	return I.sam_names[s]
+}
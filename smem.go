@@ -0,0 +1,126 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+// SMEM is a super-maximal exact match of a query: query[Start:End] occurs
+// in the text, cannot be extended on either side without losing all its
+// occurrences, and is not contained in another such match.  The embedded
+// Cursor gives its Range, its number of occurrences (Size) and Positions.
+//-----------------------------------------------------------------------------
+type SMEM struct {
+	Start, End int
+	Cursor
+}
+
+//-----------------------------------------------------------------------------
+// Returns the SMEMs of query at least minLen long, by Start.  The query is
+// normalized by I.Alphabet; symbols that do not occur in the text end
+// matches.  With a bidirectional index (BuildOptions.Bidirectional), each
+// SMEM is found by extending a match to the right, then to the left, from
+// a pivot in the query.  Otherwise they are found from right to left by
+// backward search (see match_spans).
+//-----------------------------------------------------------------------------
+func (I *IndexC) SMEMs(query []byte, minLen int) ([]SMEM, error) {
+	query, err := I.normalize_query(query)
+	if err != nil {
+		return nil, err
+	}
+	var spans [][2]int
+	if I.REV != nil {
+		spans = I.bi_smems(query)
+	} else {
+		spans = I.match_spans(query, nil)
+	}
+	smems := make([]SMEM, 0, len(spans))
+	for _, s := range spans {
+		if s[1]-s[0] >= minLen {
+			smems = append(smems, SMEM{Start: s[0], End: s[1], Cursor: I.search(query[s[0]:s[1]])})
+		}
+	}
+	return smems, nil
+}
+
+//-----------------------------------------------------------------------------
+// SMEM spans with the bidirectional index, pivot by pivot: the SMEMs
+// containing pivot x are found among the matches of query[x:end], which
+// are extended to the left together, longest first; the next pivot is the
+// end of the longest match from x.
+func (I *IndexC) bi_smems(query []byte) [][2]int {
+	var spans [][2]int
+	for x := 0; x < len(query); {
+		x = I.bi_smems_at(query, x, &spans)
+	}
+	sort.Slice(spans, func(a, b int) bool { return spans[a][0] < spans[b][0] })
+	out := spans[:0]
+	for _, s := range spans {
+		if len(out) == 0 || out[len(out)-1] != s {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (I *IndexC) bi_smems_at(query []byte, x int, spans *[][2]int) int {
+	type match struct {
+		bi  BiInterval
+		end int
+	}
+	b, _ := I.NewBiInterval()
+	if b = b.ExtendRight(query[x]); b.Size == 0 {
+		return x + 1
+	}
+
+	// matches query[x:end] that change size, shortest first
+	var curr []match
+	end := x + 1
+	for ; end < len(query); end++ {
+		next := b.ExtendRight(query[end])
+		if next.Size != b.Size {
+			curr = append(curr, match{b, end})
+		}
+		if next.Size == 0 {
+			break
+		}
+		b = next
+	}
+	if end == len(query) {
+		curr = append(curr, match{b, end})
+	}
+	for l, r := 0, len(curr)-1; l < r; l, r = l+1, r-1 {
+		curr[l], curr[r] = curr[r], curr[l]
+	}
+	ret := curr[0].end
+	first := len(*spans)
+
+	// extend them all to the left, longest first; a match that stops is
+	// an SMEM if no longer one went further
+	for i := x - 1; i >= -1; i-- {
+		var next []match
+		for _, m := range curr {
+			var ext BiInterval
+			if i >= 0 {
+				ext = m.bi.ExtendLeft(query[i])
+			}
+			if ext.Size == 0 {
+				n := len(*spans)
+				if len(next) == 0 && (n == first || i+1 < (*spans)[n-1][0]) {
+					*spans = append(*spans, [2]int{i + 1, m.end})
+				}
+			} else if len(next) == 0 || ext.Size != next[len(next)-1].bi.Size {
+				next = append(next, match{ext, m.end})
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		curr = next
+	}
+	return ret
+}
@@ -0,0 +1,84 @@
+/*
+   Copyright 2015 Vinhthuy Phan
+	Compressed FM index.
+*/
+package fmic
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+// SMEM spans of q in records, from every maximal exact match.
+func brute_smems(records []Record, q []byte) [][2]int {
+	occurs := func(a, b int) bool {
+		for _, r := range records {
+			if bytes.Contains(r.Seq, q[a:b]) {
+				return true
+			}
+		}
+		return false
+	}
+	var mems [][2]int
+	for a := 0; a < len(q); a++ {
+		for b := a + 1; b <= len(q) && occurs(a, b); b++ {
+			if (a == 0 || !occurs(a-1, b)) && (b == len(q) || !occurs(a, b+1)) {
+				mems = append(mems, [2]int{a, b})
+			}
+		}
+	}
+	var smems [][2]int
+	for _, m := range mems {
+		super := true
+		for _, o := range mems {
+			super = super && (o == m || o[0] > m[0] || m[1] > o[1])
+		}
+		if super {
+			smems = append(smems, m)
+		}
+	}
+	return smems
+}
+
+//-----------------------------------------------------------------------------
+// SMEMs agrees with brute_smems, with and without a bidirectional index, and
+// drops the matches shorter than minLen.
+func TestSMEMs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	records := random_strains(rng, 3, 800)
+	for _, bi := range []bool{false, true} {
+		I, err := BuildFromRecords(records, BuildOptions{Ratio: 8, Multiple: true, Bidirectional: bi})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < 200; n++ {
+			_, _, query := random_substring(rng, records, 10+rng.Intn(60))
+			for e := rng.Intn(6); e > 0; e-- {
+				query[rng.Intn(len(query))] = "ACGTN"[rng.Intn(5)]
+			}
+			smems, err := I.SMEMs(query, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][2]int
+			for _, m := range smems {
+				got = append(got, [2]int{m.Start, m.End})
+				if r, _ := I.Search(query[m.Start:m.End]); m.Empty() || m.Range() != r {
+					t.Fatalf("bidirectional %v: %s[%d:%d] has rows %v, want %v", bi, query, m.Start, m.End, m.Range(), r)
+				}
+			}
+			if want := brute_smems(records, query); !reflect.DeepEqual(got, want) {
+				t.Fatalf("bidirectional %v: %s has SMEMs %v, want %v", bi, query, got, want)
+			}
+			smems, _ = I.SMEMs(query, 12)
+			for _, m := range smems {
+				if m.End-m.Start < 12 {
+					t.Fatalf("bidirectional %v: %s[%d:%d] is shorter than 12", bi, query, m.Start, m.End)
+				}
+			}
+		}
+	}
+}